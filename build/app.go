@@ -0,0 +1,215 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+
+	"github.com/shynxe/greact/config"
+)
+
+// appStopTimeout is how long the app gets to shut down after SIGTERM before it is killed
+const appStopTimeout = 5 * time.Second
+
+// appProcess is a running instance of the user's Go app
+type appProcess struct {
+	cmd *exec.Cmd
+	// done is closed when the process exits
+	done chan struct{}
+	// sharedGroup is set when the app is in the process group of greact,
+	// getting the signals of the terminal directly
+	sharedGroup bool
+}
+
+// startApp starts the binary in dir with the given arguments, forwarding its
+// output, and its input with stdin. A nil env inherits the environment of
+// greact. The app runs in its own process group, so a Ctrl-C in the terminal
+// only reaches greact, which relays it once: a second SIGINT often means
+// "force quit" to Go servers. A background process group can't read the
+// terminal though, so an app reading it shares the group of greact instead.
+func startApp(binary string, dir string, args []string, env []string, stdin bool) (*appProcess, error) {
+	cmd := exec.Command(binary, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	sharedGroup := false
+	if stdin {
+		cmd.Stdin = os.Stdin
+		sharedGroup = isTerminal(os.Stdin)
+	}
+	if !sharedGroup {
+		setProcessGroup(cmd)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	app := &appProcess{cmd: cmd, done: make(chan struct{}), sharedGroup: sharedGroup}
+	go func() {
+		cmd.Wait()
+		close(app.done)
+	}()
+
+	return app, nil
+}
+
+// isTerminal reports whether f is a terminal, as opposed to a pipe or a file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// stop asks the app to shut down with SIGTERM, and kills it if it is still
+// running after appStopTimeout
+func (app *appProcess) stop() {
+	app.interrupt(syscall.SIGTERM)
+}
+
+// interrupt sends sig to the app and waits for it to exit, killing it if it
+// is still running after appStopTimeout
+func (app *appProcess) interrupt(sig os.Signal) {
+	if app == nil {
+		return
+	}
+
+	select {
+	case <-app.done:
+		return
+	default:
+	}
+
+	// signals other than kill aren't supported on windows
+	if err := app.cmd.Process.Signal(sig); err != nil {
+		app.cmd.Process.Kill()
+	}
+
+	app.wait()
+}
+
+// wait waits for the app to exit, killing it if it is still running after
+// appStopTimeout
+func (app *appProcess) wait() {
+	select {
+	case <-app.done:
+	case <-time.After(appStopTimeout):
+		fmt.Printf("[greact] app didn't stop within %s, killing it\n", appStopTimeout)
+		app.cmd.Process.Kill()
+		<-app.done
+	}
+}
+
+// buildApp builds the main package of the target into output
+func buildApp(target goTarget, output string) error {
+	args := append([]string{"build", "-o", output}, target.Flags...)
+	cmd := exec.Command("go", append(args, target.Main)...)
+	cmd.Dir = target.Dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// goTarget describes how the user's Go app is built and run
+type goTarget struct {
+	// Main is the main package, relative to Dir
+	Main string
+	// Flags are passed to go build, e.g. -tags, -ldflags or -race
+	Flags []string
+	// OutDir holds the binaries, a new temporary directory when empty
+	OutDir string
+	// Dir is the directory go build and the app run in
+	Dir string
+}
+
+// goTargetSettings returns the Go build target
+func goTargetSettings() (goTarget, error) {
+	userConfig := config.GetConfig()
+
+	main, buildFlags, outDir, dir := userConfig.GoMain, userConfig.GoBuildFlags, userConfig.GoOutDir, userConfig.GoDir
+	if dir == "" {
+		dir = config.Dir
+	}
+
+	flags, err := splitArgs(buildFlags)
+	if err != nil {
+		return goTarget{}, fmt.Errorf("invalid go build flags: %w", err)
+	}
+
+	return goTarget{Main: main, Flags: flags, OutDir: outDir, Dir: dir}, nil
+}
+
+// binDir returns a new temporary directory for the binaries, in OutDir or the
+// temporary directory of the system, and a function removing it. Nothing else
+// of OutDir is touched.
+func (t goTarget) binDir(pattern string) (string, func(), error) {
+	if t.OutDir != "" {
+		if err := os.MkdirAll(t.OutDir, 0755); err != nil {
+			return "", nil, err
+		}
+	}
+
+	dir, err := os.MkdirTemp(t.OutDir, pattern)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return dir, func() { os.RemoveAll(dir) }, nil
+}
+
+// binaryName returns the file name of a binary, with the extension of the platform
+func binaryName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+
+	return name
+}
+
+// splitArgs splits a command line on spaces, keeping quoted strings together
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %s", s)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,64 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_goTarget_binDir(t *testing.T) {
+	outDir := t.TempDir()
+	unrelated := filepath.Join(outDir, "app.go")
+	if err := os.WriteFile(unrelated, []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, cleanup, err := goTarget{OutDir: outDir}.binDir("greact-run-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(dir) != outDir {
+		t.Errorf("binDir() = %s, want a directory in %s", dir, outDir)
+	}
+	if err := os.WriteFile(filepath.Join(dir, binaryName("app")), []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup()
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("cleanup() kept %s", dir)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Errorf("cleanup() removed the unrelated %s: %v", unrelated, err)
+	}
+}
+
+func Test_splitArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []string
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"spaces", "  -race   -tags prod ", []string{"-race", "-tags", "prod"}, false},
+		{"double quotes", `-ldflags "-s -w"`, []string{"-ldflags", "-s -w"}, false},
+		{"single quotes inside an arg", `-ldflags='-X main.version=1.0'`, []string{"-ldflags=-X main.version=1.0"}, false},
+		{"empty quotes", `-tags ""`, []string{"-tags", ""}, false},
+		{"unterminated quote", `-ldflags "-s`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitArgs(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
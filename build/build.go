@@ -1,50 +1,96 @@
 package build
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/shynxe/greact/config"
+	"github.com/shynxe/greact/manifest"
+	"github.com/shynxe/greact/renderer"
 )
 
 var (
 	configPath string
 	devMode    bool
+	hmrMode    bool
+	forceBuild bool
+	// keepPartial keeps the staged client when its creation fails
+	keepPartial bool
 )
 
-// Build is the main function of the build command
-func Build(args []string) {
-	parseFlags(args)
+// Build is the main function of the build command. Bundler failures are
+// returned as a *BuildError holding the parsed diagnostics.
+func Build(args []string) error {
+	return buildCommand("build", args)
+}
+
+// buildCommand parses the flags of command and builds the pages
+func buildCommand(command string, args []string) error {
+	parseFlags(command, args)
 
 	err := loadConfig()
 	if err != nil {
 		fmt.Println(err)
-		return
+		return err
 	}
 
 	err = build()
 	if err != nil {
-		fmt.Println(err)
-		return
+		printBuildError(err)
+		return err
 	}
+
+	return nil
 }
 
-func parseFlags(args []string) {
+func parseFlags(command string, args []string) {
 	// flags:
 	// -c, --config: path to config file
 	// -dev: dev mode
-	// new flagset for build command
-	flagSet := flag.NewFlagSet("build", flag.ExitOnError)
+	// -hmr: hot module replacement, with dev mode
+	// -force: rebuild even if nothing changed
+	// -keep-partial: keep a client whose creation failed
+	// -dev-host, -dev-port, -dev-url: dev server settings, not for build
+	// -app-url, -proxy-port: dev proxy settings, not for build
+	// -env: config profile, development in dev mode and production otherwise
+	// -env-file, -e, -no-restart: app settings of the run command
+	// -go-main, -go-build-flags, -go-out-dir, -go-dir: Go build target of run and dev
+	// -set key=value: any config key
+	// the config flags take precedence over the GREACT_* variables and the config file
+	// everything after -- is passed to the app
+	// new flagset for the command
+	flagSet := flag.NewFlagSet(command, flag.ExitOnError)
 	flagSet.StringVar(&configPath, "c", "", "path to config file")
 	flagSet.StringVar(&configPath, "config", "", "path to config file")
 	flagSet.BoolVar(&devMode, "dev", false, "dev mode")
+	flagSet.BoolVar(&hmrMode, "hmr", false, "hot module replacement with React Fast Refresh (dev mode only)")
+	flagSet.BoolVar(&forceBuild, "force", false, "rebuild even if nothing changed")
+	flagSet.BoolVar(&keepPartial, "keep-partial", false, "keep the partially created client when its creation fails, for debugging")
+	if command != "build" {
+		configFlag(flagSet, "devHost", "host the dev server listens on")
+		configFlag(flagSet, "devPort", fmt.Sprintf("port the dev server listens on (default %d)", DefaultDevPort))
+		configFlag(flagSet, "devPublicURL", "public websocket URL of the dev server, e.g. wss://example.dev/ws")
+		configFlag(flagSet, "appURL", fmt.Sprintf("URL of the app behind the dev proxy (default %s)", DefaultAppURL))
+		configFlag(flagSet, "devProxyPort", fmt.Sprintf("port the dev proxy listens on (default %d)", DefaultDevProxyPort))
+	}
+	flagSet.StringVar(&envFile, "env-file", "", "file of KEY=VALUE lines added to the environment of the app")
+	flagSet.Var(profileFlag{}, "env", "config profile layered over the config file, e.g. production for greact.production.env (default $GREACT_ENV, or development in dev mode and production otherwise)")
+	flagSet.Var(&envVars, "e", "KEY=VALUE added to the environment of the app, can be repeated")
+	flagSet.BoolVar(&noRestart, "no-restart", false, "don't restart the app when it crashes")
+	configFlag(flagSet, "goMain", "main package of the Go app, relative to -go-dir (default .)")
+	configFlag(flagSet, "goBuildFlags", "flags passed to go build, e.g. \"-race -tags prod\"")
+	configFlag(flagSet, "goOutDir", "directory of the temporary greact-* folder holding the Go app binaries (default the temporary directory of the system)")
+	configFlag(flagSet, "goDir", "directory go build and the app run in (default the directory of the config file)")
+	flagSet.Var(setFlag{}, "set", "key=value overriding any config key, can be repeated")
 
 	flagSet.Usage = func() {
-		fmt.Println("usage: greact build [options]")
+		fmt.Printf("usage: greact %s [options]\n", command)
 		fmt.Println()
 		fmt.Println("options:")
 		flagSet.PrintDefaults()
@@ -52,48 +98,111 @@ func parseFlags(args []string) {
 
 	// parse flags
 	flagSet.Parse(args)
+	appArgs = flagSet.Args()
+}
+
+// configValue is a flag overriding a config key
+type configValue struct {
+	name  string
+	key   string
+	value string
+}
+
+// configFlag adds the flag of key, named by config.FlagName like the ones of init
+func configFlag(flagSet *flag.FlagSet, key string, usage string) {
+	name := config.FlagName(key)
+	flagSet.Var(&configValue{name: name, key: key}, name, usage)
+}
+
+func (f *configValue) String() string {
+	return f.value
+}
+
+func (f *configValue) Set(value string) error {
+	f.value = value
+	return config.Override(f.key, "-"+f.name, value)
+}
+
+// profileFlag selects the config profile. It used to set the environment of
+// the app, now -e, so a KEY=VALUE is refused instead of taken for a profile.
+type profileFlag struct{}
+
+func (profileFlag) String() string {
+	return config.Profile
+}
+
+func (profileFlag) Set(value string) error {
+	if strings.Contains(value, "=") {
+		return fmt.Errorf("-env selects the config profile, use -e %s to add it to the environment of the app", value)
+	}
+
+	config.Profile = value
+	return nil
+}
+
+// setFlag overrides the config key of a key=value pair
+type setFlag struct{}
+
+func (setFlag) String() string {
+	return ""
+}
+
+func (setFlag) Set(value string) error {
+	key, v, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected key=value, got %s", value)
+	}
+
+	return config.Override(key, "-set", v)
 }
 
 func loadConfig() error {
-	// if no config file is specified, use the default config file name
-	if configPath == "" {
-		configPath = config.DefaultConfigFileName
+	if config.ActiveProfile() == "" {
+		config.Profile = defaultProfile()
 	}
 
-	// load config file
+	// an empty path looks up the config file of the current directory
 	err := config.LoadConfig(configPath)
 
 	if err != nil {
 		return fmt.Errorf("invalid config file: %w", err)
 	}
 
-	// validate config file
-	err = config.ValidateConfig(config.GetConfig())
+	// validate config file, a missing client is created by the build
+	validate := config.ValidateConfig
+	if !clientExists() {
+		validate = config.ValidateNewConfig
+	}
+	err = validate(config.GetConfig())
 
 	if err != nil {
 		return fmt.Errorf("invalid config file: %w", err)
 	}
 
+	// React Fast Refresh needs the babel transform, which only the webpack bundler runs
+	if hmrMode && (!devMode || config.GetConfig().Bundler == BundlerESBuild) {
+		fmt.Println("hot module replacement needs dev mode and the webpack bundler, falling back to live reload")
+		hmrMode = false
+	}
+
 	return nil
 }
 
+// defaultProfile is the config profile of the command when none is selected
+func defaultProfile() string {
+	if devMode {
+		return "development"
+	}
+
+	return "production"
+}
+
 func build() error {
 	// create client if it doesn't exist
 	if !clientExists() {
-		err := createClient()
-		// TODO: create rollback method for client creation
-		if err != nil {
-			return fmt.Errorf("error creating client: %w", err)
-		}
-
-		err = createBuildPath()
+		err := scaffoldClient()
 		if err != nil {
-			return fmt.Errorf("error creating default greact folder: %w", err)
-		}
-
-		err = createHydrater()
-		if err != nil {
-			return fmt.Errorf("error creating hydrater: %w", err)
+			return err
 		}
 	} else if err := clientValid(); err != nil {
 		return fmt.Errorf("invalid client: %w", err)
@@ -111,17 +220,90 @@ func build() error {
 
 	// build client
 	fmt.Println("building pages...")
-	err = buildClient()
+	built, err := buildClient()
 	if err != nil {
 		return fmt.Errorf("error building pages: %w", err)
 	}
+	if !built {
+		return nil
+	}
 
-	// print count of .html files in build folder
-	fmt.Printf("successfully built %d pages!\n", countHTMLFiles())
+	// print count of pages in the manifest
+	fmt.Printf("successfully built %d pages!\n", countManifestPages())
 
 	return nil
 }
 
+// scaffoldClient creates the client with a sample page and installs its
+// dependencies. The client is staged in a temporary directory next to it and
+// only moved into place once complete, so a failed install leaves nothing
+// behind unless -keep-partial is set.
+func scaffoldClient() error {
+	clientPath := config.GetConfig().ClientPath
+
+	parent := filepath.Dir(clientPath)
+	err := os.MkdirAll(parent, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("error creating client: %w", err)
+	}
+
+	// on the same filesystem as the client, so that it can be renamed
+	staging, err := os.MkdirTemp(parent, ".greact-client-*")
+	if err != nil {
+		return fmt.Errorf("error creating client: %w", err)
+	}
+
+	err = stageClient(staging)
+	if err == nil {
+		// MkdirTemp creates the directory for the owner only
+		err = os.Chmod(staging, 0755)
+	}
+	if err == nil {
+		err = os.Rename(staging, clientPath)
+	}
+	if err != nil {
+		return rollbackClient(staging, err)
+	}
+
+	return nil
+}
+
+// stageClient creates a complete client in dir
+func stageClient(dir string) error {
+	err := createClient(dir)
+	if err != nil {
+		return err
+	}
+
+	buildPath := filepath.Join(dir, config.GetConfig().BuildFolder)
+	err = createBuildPath(buildPath)
+	if err != nil {
+		return fmt.Errorf("error creating default greact folder: %w", err)
+	}
+
+	err = createHydrater(buildPath)
+	if err != nil {
+		return fmt.Errorf("error creating hydrater: %w", err)
+	}
+
+	return nil
+}
+
+// rollbackClient removes the staged client after err, or keeps it with -keep-partial
+func rollbackClient(staging string, err error) error {
+	clientPath := config.GetConfig().ClientPath
+
+	if keepPartial {
+		fmt.Printf("client creation failed, the partial client is kept in %s\n", staging)
+	} else if rmErr := os.RemoveAll(staging); rmErr != nil {
+		fmt.Printf("client creation failed, and removing %s failed too: %v\n", staging, rmErr)
+	} else {
+		fmt.Printf("client creation failed, %s wasn't created (use -keep-partial to keep the partial client)\n", clientPath)
+	}
+
+	return fmt.Errorf("error creating client: %w", err)
+}
+
 func getSourcePageNames() []string {
 	files, err := os.ReadDir(config.SourcePath)
 	if err != nil {
@@ -143,44 +325,25 @@ func getSourcePageNames() []string {
 	return pageNames
 }
 
-func countHTMLFiles() int {
-	files, err := os.ReadDir(config.StaticPath)
+func countManifestPages() int {
+	m, err := manifest.Load(filepath.Join(config.StaticPath, manifest.FileName))
 	if err != nil {
 		fmt.Println(err)
 		return -1
 	}
 
-	count := 0
-	for _, file := range files {
-		filename := file.Name()
-		fileExt := filepath.Ext(filename)
-
-		if fileExt == ".html" {
-			count++
-		}
-	}
-
-	return count
+	return len(m.Pages)
 }
 
-func createBuildPath() error {
-	os.MkdirAll(config.BuildPath, os.ModePerm)
-
-	return nil
+func createBuildPath(buildPath string) error {
+	return os.MkdirAll(buildPath, os.ModePerm)
 }
 
-func createHydrater() error {
-	rendererPath := config.BuildPath + "/.greact-hydrater.js"
-	_, err := os.Create(rendererPath)
-	if err != nil {
-		return err
-	}
+func createHydrater(buildPath string) error {
+	rendererPath := filepath.Join(buildPath, ".greact-hydrater.js")
 
-	err = os.WriteFile(
-		rendererPath,
-		[]byte(hydrater),
-		os.ModePerm,
-	)
+	// an open file would keep the staged client from being renamed on windows
+	err := os.WriteFile(rendererPath, []byte(hydrater), 0644)
 	if err != nil {
 		return err
 	}
@@ -189,11 +352,7 @@ func createHydrater() error {
 }
 
 func createRenderer() error {
-	rendererPath := config.BuildPath + "/.greact-renderer.js"
-	_, err := os.Create(rendererPath)
-	if err != nil {
-		return err
-	}
+	rendererPath := filepath.Join(config.BuildPath, ".greact-renderer.js")
 
 	renderer := "import React from 'react';\nimport * as ReactDOMServer from 'react-dom/server';\n"
 
@@ -224,11 +383,7 @@ func createRenderer() error {
 	// export render function
 	renderer += "export default render;\n"
 
-	err = os.WriteFile(
-		rendererPath,
-		[]byte(renderer),
-		os.ModePerm,
-	)
+	err := os.WriteFile(rendererPath, []byte(renderer), 0644)
 	if err != nil {
 		return err
 	}
@@ -236,31 +391,28 @@ func createRenderer() error {
 	return nil
 }
 
+// createHTMLTemplate validates the document template of the config, or the
+// default one, and writes it next to the renderer
 func createHTMLTemplate() error {
-	templatePath := config.BuildPath + "/.greact-template.html"
-	_, err := os.Create(templatePath)
-	if err != nil {
-		return err
-	}
+	name, text := "default template", HTMLTemplate
 
-	// if devMode, add refreshScript to HTMLTemplate
-	var htmlTemplate string
-	if devMode {
-		htmlTemplate = strings.Replace(HTMLTemplate, "</head>", refreshScript+"</head>", 1)
-	} else {
-		htmlTemplate = HTMLTemplate
+	if userTemplate := config.GetConfig().Template; userTemplate != "" {
+		name = filepath.Join(config.GetConfig().ClientPath, userTemplate)
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		text = string(data)
 	}
 
-	err = os.WriteFile(
-		templatePath,
-		[]byte(htmlTemplate),
-		os.ModePerm,
-	)
+	// a missing slot fails the build instead of the rendering
+	_, err := renderer.ParseTemplate(name, text)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// in dev mode the dev proxy injects the refresh script into the responses
+	return os.WriteFile(filepath.Join(config.BuildPath, renderer.TemplateFileName), []byte(text), 0644)
 }
 
 func clientValid() error {
@@ -270,118 +422,140 @@ func clientValid() error {
 	}
 
 	// check if node_modules directory exists
-	if _, err := os.Stat(config.GetConfig().ClientPath + "/node_modules"); os.IsNotExist(err) {
+	if _, err := os.Stat(filepath.Join(config.GetConfig().ClientPath, "node_modules")); os.IsNotExist(err) {
 		return fmt.Errorf("node_modules directory does not exist")
 	}
 
 	return nil
 }
 
-func createClient() error {
+// createClient creates the client in clientPath
+func createClient(clientPath string) error {
 	// create clientPath directory
 	fmt.Println("creating client...")
-	err := os.MkdirAll(config.GetConfig().ClientPath, os.ModePerm)
+	err := os.MkdirAll(clientPath, os.ModePerm)
 	if err != nil {
 		return err
 	}
 
 	// create clientPath/src directory and add a simple index.js file react page
-	err = os.MkdirAll(config.SourcePath, os.ModePerm)
-	if err != nil {
-		return err
-	}
-
-	indexFile, err := os.Create(config.SourcePath + "/index.js")
+	sourcePath := filepath.Join(clientPath, config.GetConfig().SourceFolder)
+	err = os.MkdirAll(sourcePath, os.ModePerm)
 	if err != nil {
 		return err
 	}
 
 	// write sample react page to index.js
-	_, err = indexFile.WriteString(reactSamplePage)
+	err = os.WriteFile(filepath.Join(sourcePath, "index.js"), []byte(reactSamplePage), 0644)
 	if err != nil {
 		return err
 	}
 
-	// create package.json file
-	packageFile, err := os.Create(config.GetConfig().ClientPath + "/package.json")
-	packageFile.WriteString(packageJSON)
+	// create package.json file with all dependencies
+	err = writePackageJSON(clientPath)
 	if err != nil {
 		return err
 	}
 
 	// install dependencies
-	err = installDependencies()
+	err = installDependencies(clientPath)
 	return err
 }
 
-func installDependencies() error {
-	fmt.Println("installing dependencies...")
-
-	currentDir, _ := os.Getwd()
-	err := os.Chdir(config.GetConfig().ClientPath)
+// buildClient runs the client and server builds concurrently, skipping the ones
+// whose inputs didn't change since their last build. When one of them fails
+// the other is cancelled, and the errors of both are returned. built is false
+// when every build was skipped.
+func buildClient() (built bool, err error) {
+	b, err := getBundler()
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	var dependencies = []string{
-		"react",
-		"react-dom",
+	bundles := []struct {
+		target string
+		build  func(context.Context) error
+	}{
+		{TargetClient, b.BuildClient},
+		{TargetServer, b.BuildServer},
 	}
 
-	var devDependencies = []string{
-		"@babel/cli",
-		"@babel/core",
-		"@babel/plugin-transform-react-jsx-source",
-		"@babel/preset-react",
-		"babel-loader",
-		"html-webpack-plugin",
-		"webpack",
-		"webpack-cli",
-		"webpack-dev-server",
+	// without keys there's nothing to compare against, so everything is rebuilt
+	keys, keysErr := buildCacheKeys()
+	cache := loadBuildCache()
+
+	var targets []string
+	var builds []func(context.Context) error
+	for _, bundle := range bundles {
+		// in HMR mode the client build only (re)starts the webpack watcher, so it always runs
+		watching := hmrMode && bundle.target == TargetClient
+		if forceBuild || keysErr != nil || watching || !cache.fresh(bundle.target, keys[bundle.target]) {
+			targets = append(targets, bundle.target)
+			builds = append(builds, bundle.build)
+		}
 	}
 
-	// install dependencies
-	for _, dependency := range dependencies {
-		output := exec.Command("npm", "install", dependency)
-		if err := output.Run(); err != nil {
-			return err
+	if len(builds) == 0 {
+		fmt.Println("pages are up to date, skipping build (use -force to rebuild)")
+		return false, nil
+	}
+
+	errs := runBuilds(builds)
+
+	if keysErr == nil {
+		for i, target := range targets {
+			if errs[i] == nil {
+				cache[target] = keys[target]
+			} else {
+				delete(cache, target)
+			}
+		}
+
+		if err := cache.save(); err != nil {
+			fmt.Println("error saving build cache:", err)
 		}
 	}
 
-	// install dev dependencies
-	for _, dependency := range devDependencies {
-		output := exec.Command("npm", "install", dependency, "--save-dev")
-		if err := output.Run(); err != nil {
-			return err
+	// the cancelled build only failed because its sibling did
+	var buildErrs BuildErrors
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			buildErrs = append(buildErrs, err)
 		}
 	}
 
-	os.Chdir(currentDir)
-	return nil
+	switch len(buildErrs) {
+	case 0:
+		return true, nil
+	case 1:
+		return true, buildErrs[0]
+	default:
+		return true, buildErrs
+	}
 }
 
-func buildClient() error {
-	// call webpack in clientPath directory
-	err := createWebpackConfig()
-	if err != nil {
-		return fmt.Errorf("error creating webpack config: %w", err)
-	}
+// runBuilds runs the builds concurrently, cancelling the others when one fails
+func runBuilds(builds []func(context.Context) error) []error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	currentDir, _ := os.Getwd()
-	os.Chdir(config.GetConfig().ClientPath)
-	output := exec.Command("npx", "webpack", "--mode", "production")
-	if err := output.Run(); err != nil {
-		return err
-	}
+	errs := make([]error, len(builds))
 
-	// now call it with config server-webpack.config.js
-	output = exec.Command("npx", "webpack", "--mode", "production", "--config", "server-webpack.config.js")
-	if err := output.Run(); err != nil {
-		return err
+	var wg sync.WaitGroup
+	for i, build := range builds {
+		wg.Add(1)
+		go func(i int, build func(context.Context) error) {
+			defer wg.Done()
+
+			if err := build(ctx); err != nil {
+				errs[i] = err
+				cancel()
+			}
+		}(i, build)
 	}
+	wg.Wait()
 
-	os.Chdir(currentDir)
-	return nil
+	return errs
 }
 
 func clientExists() bool {
@@ -413,37 +587,8 @@ const App = ({name}) => {
 
 export default App;`
 
-const packageJSON = `{
-	"name": "greact",
-	"version": "1.0.0",
-	"author": ""
-}`
-
-const HTMLTemplate = `<html>
-
-<head>
-  <title>SSR Demo</title>
-  <meta charset="utf-8" />
-</head>
-
-<body>
-  <div id="root">{{SSR}}</div>
-</body>
-<script>
-  const hydrateDOM = (fn) => {
-    if (document.readyState != 'loading') {
-      fn();
-    } else {
-      document.addEventListener('DOMContentLoaded', fn);
-    }
-  }
-
-  hydrateDOM(function () {
-    // {{__HYDRATION__}}
-  })
-</script>
-
-</html>`
+// HTMLTemplate is the document of the pages when the config has no template
+const HTMLTemplate = renderer.DefaultTemplate
 
 const hydrater = `import React from 'react';
 import ReactDOM from 'react-dom';
@@ -452,8 +597,8 @@ const _page = ({component, props}) => {
     return React.createElement(component, props);    
 }
 
-const hydrate = (component, props) => {
-    ReactDOM.hydrate(_page({component, props}), document.getElementById('root'));
+const hydrate = (component, props, container) => {
+    ReactDOM.hydrate(_page({component, props}), container || document.getElementById('root'));
 }
 
 export default hydrate;`
@@ -0,0 +1,81 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/shynxe/greact/config"
+)
+
+func Test_scaffoldClient(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	defer func() { config.Profile, keepPartial = "", false }()
+
+	tests := []struct {
+		name        string
+		install     string
+		keepPartial bool
+		wantClient  bool
+		wantStaged  bool
+	}{
+		{name: "install succeeds", install: "mkdir node_modules", wantClient: true},
+		{name: "install fails", install: "mkdir node_modules; exit 1"},
+		{name: "install fails with -keep-partial", install: "exit 1", keepPartial: true, wantStaged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// a stub npm runs the install script
+			bin := filepath.Join(dir, "bin")
+			os.Mkdir(bin, os.ModePerm)
+			os.WriteFile(filepath.Join(bin, "npm"), []byte("#!/bin/sh\n"+tt.install+"\n"), 0755)
+			t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+			configFile := filepath.Join(dir, "greact.env")
+			os.WriteFile(configFile, []byte("CLIENTPATH=./client\nSOURCEFOLDER=src\nBUILDFOLDER=build\nSTATICFOLDER=static\nPUBLICPATH=/\nPACKAGEMANAGER=npm\n"), 0644)
+			if err := config.LoadConfig(configFile); err != nil {
+				t.Fatal(err)
+			}
+			if err := config.ValidateNewConfig(config.GetConfig()); err != nil {
+				t.Fatal(err)
+			}
+			keepPartial = tt.keepPartial
+
+			err := scaffoldClient()
+			if (err == nil) != tt.wantClient {
+				t.Fatalf("scaffoldClient() error = %v, want client %v", err, tt.wantClient)
+			}
+
+			clientPath := filepath.Join(dir, "client")
+			for _, path := range []string{clientPath, filepath.Join(clientPath, "src", "index.js"), filepath.Join(clientPath, "build", ".greact-hydrater.js")} {
+				if _, err := os.Stat(path); (err == nil) != tt.wantClient {
+					t.Errorf("%s exists = %v, want %v", path, err == nil, tt.wantClient)
+				}
+			}
+
+			staged, _ := filepath.Glob(filepath.Join(dir, ".greact-client-*", "package.json"))
+			if (len(staged) > 0) != tt.wantStaged {
+				t.Errorf("staged client = %v, want kept %v", staged, tt.wantStaged)
+			}
+		})
+	}
+}
+
+func Test_profileFlag(t *testing.T) {
+	defer func() { config.Profile = "" }()
+
+	if err := (profileFlag{}).Set("staging"); err != nil || config.Profile != "staging" {
+		t.Errorf("profileFlag.Set(staging) = %v with profile %q, want nil with staging", err, config.Profile)
+	}
+
+	// -env used to take the variables of the app
+	if err := (profileFlag{}).Set("PORT=8081"); err == nil {
+		t.Errorf("profileFlag.Set(PORT=8081) = nil, want an error")
+	}
+}
@@ -0,0 +1,63 @@
+package build
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shynxe/greact/config"
+)
+
+// Bundler builds the client chunks (plus the manifest) and the server render
+// bundle. Both builds run concurrently and stop early when ctx is cancelled.
+type Bundler interface {
+	// BuildClient bundles the pages and writes the chunks and the manifest to the static folder
+	BuildClient(ctx context.Context) error
+	// BuildServer bundles the renderer into render.js in the build folder
+	BuildServer(ctx context.Context) error
+	// Close releases the resources kept between builds, e.g. watch processes
+	Close() error
+}
+
+const (
+	BundlerWebpack = "webpack"
+	BundlerESBuild = "esbuild"
+)
+
+// bundler is kept between builds so that dev rebuilds can be incremental
+var bundler Bundler
+
+func getBundler() (Bundler, error) {
+	if bundler != nil {
+		return bundler, nil
+	}
+
+	b, err := newBundler(config.GetConfig().Bundler)
+	if err != nil {
+		return nil, err
+	}
+
+	bundler = b
+	return bundler, nil
+}
+
+func newBundler(name string) (Bundler, error) {
+	switch name {
+	case "", BundlerWebpack:
+		return &webpackBundler{}, nil
+	case BundlerESBuild:
+		return &esbuildBundler{}, nil
+	default:
+		return nil, fmt.Errorf("unknown bundler: %s", name)
+	}
+}
+
+// closeBundler closes the bundler, if any was used
+func closeBundler() {
+	if bundler == nil {
+		return
+	}
+
+	if err := bundler.Close(); err != nil {
+		fmt.Println("error closing bundler:", err)
+	}
+}
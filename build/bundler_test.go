@@ -0,0 +1,78 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeBundler struct {
+	client func(ctx context.Context) error
+	server func(ctx context.Context) error
+}
+
+func (b *fakeBundler) BuildClient(ctx context.Context) error { return b.client(ctx) }
+func (b *fakeBundler) BuildServer(ctx context.Context) error { return b.server(ctx) }
+func (b *fakeBundler) Close() error                          { return nil }
+
+func Test_buildClient(t *testing.T) {
+	clientErr := &BuildError{Target: TargetClient, Err: errors.New("client failed")}
+	serverErr := &BuildError{Target: TargetServer, Err: errors.New("server failed")}
+
+	// waits for the sibling build to cancel it
+	cancelled := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return errors.New("not cancelled")
+		}
+	}
+	ok := func(ctx context.Context) error { return nil }
+
+	tests := []struct {
+		name    string
+		bundler *fakeBundler
+		want    error
+	}{
+		{
+			name:    "Test both succeed",
+			bundler: &fakeBundler{client: ok, server: ok},
+			want:    nil,
+		},
+		{
+			name:    "Test client failure cancels server",
+			bundler: &fakeBundler{client: func(ctx context.Context) error { return clientErr }, server: cancelled},
+			want:    clientErr,
+		},
+		{
+			name:    "Test server failure cancels client",
+			bundler: &fakeBundler{client: cancelled, server: func(ctx context.Context) error { return serverErr }},
+			want:    serverErr,
+		},
+		{
+			name: "Test both failures are reported",
+			bundler: &fakeBundler{
+				client: func(ctx context.Context) error { return clientErr },
+				server: func(ctx context.Context) error { return serverErr },
+			},
+			want: BuildErrors{clientErr, serverErr},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundler = tt.bundler
+			defer func() { bundler = nil }()
+
+			built, err := buildClient()
+			if !reflect.DeepEqual(err, tt.want) {
+				t.Errorf("buildClient() = %v, want %v", err, tt.want)
+			}
+			if !built {
+				t.Errorf("buildClient() skipped the builds")
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/shynxe/greact/config"
+	"github.com/shynxe/greact/manifest"
+)
+
+const buildCacheFileName = ".greact-cache.json"
+
+// buildCache maps every target to the hash of the inputs of its last successful build
+type buildCache map[string]string
+
+// generatedFiles are rewritten by the bundlers on every build, and derive from
+// the config and the source files which are already hashed
+var generatedFiles = map[string]bool{
+	"webpack.config.js":        true,
+	"server-webpack.config.js": true,
+}
+
+func loadBuildCache() buildCache {
+	cache := buildCache{}
+
+	data, err := os.ReadFile(filepath.Join(config.BuildPath, buildCacheFileName))
+	if err != nil {
+		return cache
+	}
+
+	// a corrupt cache only means everything gets rebuilt
+	json.Unmarshal(data, &cache)
+	return cache
+}
+
+func (c buildCache) save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(config.BuildPath, buildCacheFileName), data, 0644)
+}
+
+// fresh reports whether the target was built from the same inputs and its output still exists
+func (c buildCache) fresh(target string, key string) bool {
+	if c[target] == "" || c[target] != key {
+		return false
+	}
+
+	output := filepath.Join(config.StaticPath, manifest.FileName)
+	if target == TargetServer {
+		output = filepath.Join(config.BuildPath, "render.js")
+	}
+
+	_, err := os.Stat(output)
+	return err == nil
+}
+
+// buildCacheKeys hashes the inputs of the client and server builds: the
+// config, every file of the client (minus the dependencies and the build
+// outputs) and the generated entry of each side.
+func buildCacheKeys() (map[string]string, error) {
+	shared := sha256.New()
+
+	// the config and the mode change the bundler options
+	err := json.NewEncoder(shared).Encode(config.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(shared, devMode, hmrMode)
+
+	err = hashClientFiles(shared)
+	if err != nil {
+		return nil, err
+	}
+	sum := shared.Sum(nil)
+
+	keys := map[string]string{}
+	for target, entry := range map[string]string{
+		TargetClient: ".greact-hydrater.js",
+		TargetServer: ".greact-renderer.js",
+	} {
+		h := sha256.New()
+		h.Write(sum)
+
+		err = hashFile(h, filepath.Join(config.BuildPath, entry))
+		if err != nil {
+			return nil, err
+		}
+
+		keys[target] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return keys, nil
+}
+
+func hashClientFiles(h hash.Hash) error {
+	clientPath := filepath.Clean(config.GetConfig().ClientPath)
+	skipDirs := map[string]bool{
+		filepath.Join(clientPath, "node_modules"): true,
+		filepath.Clean(config.BuildPath):          true,
+		filepath.Clean(config.StaticPath):         true,
+	}
+
+	return filepath.WalkDir(clientPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if skipDirs[path] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if generatedFiles[d.Name()] && filepath.Dir(path) == clientPath {
+			return nil
+		}
+
+		// hash the path too, so renames and new empty files count as changes
+		fmt.Fprintln(h, path)
+		return hashFile(h, path)
+	})
+}
+
+func hashFile(h hash.Hash, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(h, f)
+	return err
+}
@@ -0,0 +1,121 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shynxe/greact/config"
+)
+
+func Test_buildCacheKeys(t *testing.T) {
+	dir := t.TempDir()
+	clientPath := filepath.Join(dir, "client")
+	configFile := filepath.Join(dir, "greact.env")
+
+	err := os.WriteFile(configFile, []byte("CLIENTPATH="+clientPath+"\nSOURCEFOLDER=pages\nBUILDFOLDER=build\nSTATICFOLDER=static\nPUBLICPATH=/public/\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(config.Snapshot())
+	if err := config.LoadConfig(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"pages/index.js":              "export default () => null;",
+		"build/.greact-hydrater.js":   "hydrater",
+		"build/.greact-renderer.js":   "renderer",
+		"node_modules/react/index.js": "react",
+	}
+	for name, contents := range files {
+		path := filepath.Join(clientPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys := func() map[string]string {
+		keys, err := buildCacheKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+	write := func(name string, contents string) {
+		if err := os.WriteFile(filepath.Join(clientPath, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	initial := keys()
+
+	// outputs and dependencies are not inputs
+	write("node_modules/react/index.js", "react 2")
+	write("build/render.js", "bundle")
+	if got := keys(); got[TargetClient] != initial[TargetClient] || got[TargetServer] != initial[TargetServer] {
+		t.Errorf("buildCacheKeys() changed without input changes")
+	}
+
+	// the renderer only affects the server
+	write("build/.greact-renderer.js", "renderer 2")
+	renderer := keys()
+	if renderer[TargetClient] != initial[TargetClient] || renderer[TargetServer] == initial[TargetServer] {
+		t.Errorf("buildCacheKeys() after renderer change = %v, want only the server key changed", renderer)
+	}
+
+	// a source file affects both
+	write("pages/index.js", "export default () => 'changed';")
+	source := keys()
+	if source[TargetClient] == renderer[TargetClient] || source[TargetServer] == renderer[TargetServer] {
+		t.Errorf("buildCacheKeys() after source change = %v, want both keys changed", source)
+	}
+}
+
+func Test_buildClient_cache(t *testing.T) {
+	dir := t.TempDir()
+	clientPath := filepath.Join(dir, "client")
+	configFile := filepath.Join(dir, "greact.env")
+
+	err := os.WriteFile(configFile, []byte("CLIENTPATH="+clientPath+"\nSOURCEFOLDER=pages\nBUILDFOLDER=build\nSTATICFOLDER=static\nPUBLICPATH=/public/\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(config.Snapshot())
+	if err := config.LoadConfig(configFile); err != nil {
+		t.Fatal(err)
+	}
+	// the entries the cache keys hash and the outputs it checks
+	for _, name := range []string{"pages/index.js", "build/.greact-hydrater.js", "build/.greact-renderer.js", "build/render.js", "static/manifest.json"} {
+		path := filepath.Join(clientPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var runs int32
+	count := func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}
+	bundler = &fakeBundler{client: count, server: count}
+	defer func() { bundler = nil }()
+
+	if built, err := buildClient(); err != nil || !built {
+		t.Fatalf("buildClient() = %v, %v, want true, nil", built, err)
+	}
+
+	// nothing changed, both targets are skipped
+	atomic.StoreInt32(&runs, 0)
+	if built, err := buildClient(); err != nil || built || runs != 0 {
+		t.Errorf("buildClient() = %v, %v with %d builds, want false, nil with none", built, err, runs)
+	}
+}
@@ -0,0 +1,50 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/shynxe/greact/config"
+)
+
+// Config is the main function of the config command
+func Config(args []string) {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Println("usage: greact config show [options]")
+		return
+	}
+
+	parseFlags("config show", args[1:])
+
+	err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Println("invalid config file:", err)
+		os.Exit(1)
+	}
+
+	showConfig()
+
+	// the merged config is shown even when invalid, to help fixing it
+	if err := config.ValidateConfig(config.GetConfig()); err != nil {
+		fmt.Println()
+		fmt.Println("invalid config:", err)
+		os.Exit(1)
+	}
+}
+
+// showConfig prints every config key with its effective value and where it comes from
+func showConfig() {
+	fmt.Println("precedence: flags > GREACT_* environment variables > profile file > config file > defaults")
+	if profile := config.ActiveProfile(); profile != "" {
+		fmt.Println("profile:", profile)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, field := range config.Fields() {
+		fmt.Fprintf(w, "%s\t%v\t%s\n", field.Key, field.Value, field.Source)
+	}
+	w.Flush()
+}
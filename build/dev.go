@@ -1,43 +1,97 @@
 package build
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/gorilla/websocket"
 	"github.com/shynxe/greact/config"
+	"github.com/shynxe/greact/renderer"
 )
 
-var DevWebSocket *websocket.Conn
-var timer *time.Timer
+// devHub broadcasts the dev messages to every open browser tab
+var devHub = newHub(currentBuildResultMessage)
+
+var (
+	timer   *time.Timer
+	timerMu sync.Mutex
+)
 
 const refreshDebounce = time.Millisecond * 200
 
 // Dev is the main function of the dev command
 func Dev(args []string) {
-	args = append(args, "-dev")
-	Build(args)
+	// -dev goes first, everything after -- is for the app
+	parseFlags("dev", append([]string{"-dev"}, args...))
+
+	err := loadConfig()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// listen first, the injected refresh script needs the port
+	listener, err := listenDevSocket()
+	if err != nil {
+		fmt.Println("error starting dev server:", err)
+		return
+	}
 
-	go initDevSocket()
-	go watchClient(config.StaticPath, handleRefreshClient)
-	go watchClient(config.SourcePath, handleBuildClient)
-	watchServer()
+	appURL, _, healthPath := proxySettings()
+	target, err := url.Parse(appURL)
+	if err != nil {
+		fmt.Println("invalid app URL:", err)
+		return
+	}
+
+	proxyListener, err := listenDevProxy()
+	if err != nil {
+		fmt.Println("error starting dev proxy:", err)
+		return
+	}
+	proxy := newDevProxy(target, healthPath)
+
+	err = build()
+	if err != nil {
+		printBuildError(err)
+	}
+	reportBuildResult(err)
+
+	go serveDevSocket(listener)
+	go serveDevProxy(proxyListener, proxy)
+	go watchTree(config.StaticPath, nil, handleRefreshClient)
+	go watchTree(config.GetConfig().ClientPath, ignoredClientPath, handleBuildClient)
+	watchServer(proxy)
 }
 
 func handleRefreshClient(e fsnotify.Event) {
+	timerMu.Lock()
+	defer timerMu.Unlock()
+
 	if timer == nil {
 		timer = time.AfterFunc(refreshDebounce, func() {
-			if err := DevWebSocket.WriteMessage(websocket.TextMessage, []byte("refresh")); err != nil {
-				fmt.Println("Error sending refresh to websocket:", err)
-			}
+			timerMu.Lock()
 			timer = nil
+			timerMu.Unlock()
+
+			// in HMR mode the static folder changes with every hot update
+			if hmrMode {
+				devHub.Broadcast(devMessage{Type: "hmr"})
+			} else {
+				devHub.Broadcast(devMessage{Type: "refresh"})
+			}
 		})
 	} else {
 		timer.Reset(refreshDebounce)
@@ -45,177 +99,171 @@ func handleRefreshClient(e fsnotify.Event) {
 }
 
 func handleBuildClient(e fsnotify.Event) {
-	build()
-}
-
-func initDevSocket() {
-	var upgrader = websocket.Upgrader{}
-	upgrader.CheckOrigin = func(r *http.Request) bool { return true }
-
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		var err error
-		DevWebSocket, err = upgrader.Upgrade(w, r, nil)
-		if err != nil {
-			fmt.Println("Error upgrading websocket:", err)
-			http.Error(w, "Could not upgrade websocket connection", http.StatusInternalServerError)
-			return
-		}
+	// hot updates can't replace the document or add pages, so a changed
+	// template or renderer (which lists the pages) needs a full reload
+	shell := hashShellFiles()
 
-		for {
-			_, _, err := DevWebSocket.ReadMessage()
-			if err != nil {
-				break
-			}
-		}
+	err := build()
+	if err != nil {
+		printBuildError(err)
+	}
 
-		defer DevWebSocket.Close()
-	})
+	reportBuildResult(err)
 
-	http.ListenAndServe(":1501", nil)
+	if hmrMode && err == nil && hashShellFiles() != shell {
+		devHub.Broadcast(devMessage{Type: "refresh"})
+	}
 }
 
-// Watch the client source directory for changes
-func watchClient(path string, onChange func(e fsnotify.Event)) {
-	clientWatcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatal(err)
+// hashShellFiles hashes the generated template and renderer
+func hashShellFiles() string {
+	h := sha256.New()
+	for _, name := range []string{renderer.TemplateFileName, ".greact-renderer.js"} {
+		hashFile(h, filepath.Join(config.BuildPath, name))
 	}
-	defer clientWatcher.Close()
 
-	err = clientWatcher.Add(path)
-	if err != nil {
-		log.Fatal(err)
-	}
+	return hex.EncodeToString(h.Sum(nil))
+}
 
-	for {
-		select {
-		case event := <-clientWatcher.Events:
-			onChange(event)
-		case err := <-clientWatcher.Errors:
-			log.Println("error:", err)
-		}
+func serveDevSocket(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.Handle("/ws", devHub)
+
+	if err := http.Serve(listener, mux); err != nil {
+		log.Println("Error serving dev websocket:", err)
 	}
 }
 
-// Watch the current directory (only the ".go" files)
-func watchServer() {
-	serverWatcher, err := fsnotify.NewWatcher()
+// watchServer builds and runs the Go app, and rebuilds it when a Go file of
+// the module, go.mod or go.sum changes. The running app is only replaced once
+// the new binary is built, so a compile error keeps the previous one serving.
+// The proxy holds the requests back during the swap, and the browser reloads
+// once the new app passes the health check.
+func watchServer(proxy *devProxy) {
+	target, err := goTargetSettings()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer serverWatcher.Close()
 
-	err = serverWatcher.Add(".")
+	binDir, cleanup, err := target.binDir("greact-dev-")
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Create a channel to receive signals and register it to receive SIGINT signals
-	sigchan := make(chan os.Signal, 1)
-
-	var cmd *exec.Cmd
-	shouldReturn := buildApp(cmd)
-	if shouldReturn {
-		return
-	}
-
-	signal.Notify(sigchan, syscall.SIGINT)
-
-	cmd, shouldReturn1 := runApp(cmd)
-	if shouldReturn1 {
-		return
+	// the app gets the environment of greact run, and renders the dev client
+	// slot unless -e says otherwise
+	env, err := appEnv(envFile, append([]string{renderer.DevEnv + "=true"}, envVars...))
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// Start a goroutine to listen for SIGINT signals and terminate the cmd process when one is received
-	go killApp(cmd, sigchan)
-
-	onChange := func(e fsnotify.Event) {
-		fileExt := e.Name[len(e.Name)-3:]
-		if fileExt == ".go" {
-			// Kill the previous command
-			if cmd != nil {
-				if err := cmd.Process.Kill(); err != nil {
-					log.Println("Error killing command: ", err)
-				}
+	var (
+		app   *appProcess
+		appMu sync.Mutex
+		count int
+	)
+
+	// restart builds a new binary next to the running one, then swaps the processes
+	restart := func() {
+		appMu.Lock()
+		defer appMu.Unlock()
+
+		count++
+		binary := filepath.Join(binDir, binaryName(fmt.Sprintf("app-%d", count)))
+		if err := buildApp(target, binary); err != nil {
+			if app != nil {
+				log.Println("Error building app, keeping the running one:", err)
+			} else {
+				log.Println("Error building app:", err)
+				proxy.failed(err)
 			}
+			return
+		}
 
-			// Build "./app"
-			cmd = exec.Command("go", "build", "-o", "app")
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			if err := cmd.Run(); err != nil {
-				log.Println("Error building command: ", err)
-				return
-			}
+		proxy.restarting()
 
-			signal.Notify(sigchan, syscall.SIGINT)
+		if app != nil {
+			app.stop()
+			os.Remove(app.cmd.Path)
+		}
 
-			// Run "./app"
-			cmd = exec.Command("./app")
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			if err := cmd.Start(); err != nil {
-				log.Println("Error starting command: ", err)
+		app, err = startApp(binary, target.Dir, appArgs, env, false)
+		if err != nil {
+			log.Println("Error starting app:", err)
+			proxy.failed(err)
+			return
+		}
+
+		go func() {
+			if err := proxy.waitHealthy(); err != nil {
+				log.Println("Error waiting for app:", err)
 				return
 			}
 
-			// Start a goroutine to listen for SIGINT signals and terminate the cmd process when one is received
-			go func() {
-				sig := <-sigchan
-				fmt.Println("[greact] hope you developed something awesome! (received signal: ", sig, ")")
-				cmd.Process.Kill()
-				os.Exit(0)
-			}()
-
-			defer os.Remove("app")
-		}
+			devHub.Broadcast(devMessage{Type: "refresh"})
+		}()
 	}
 
-	for {
-		select {
-		case event := <-serverWatcher.Events:
-			onChange(event)
-		case err := <-serverWatcher.Errors:
-			log.Println("error:", err)
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigchan
+		fmt.Println("[greact] hope you developed something awesome! (received signal: ", sig, ")")
+
+		appMu.Lock()
+		app.stop()
+		cleanup()
+		closeBundler()
+		os.Exit(0)
+	}()
+
+	restart()
+
+	// editors write several events per save, so debounce the rebuilds
+	var timer *time.Timer
+	var timerMu sync.Mutex
+	watchTree(target.Dir, ignoredServerPath, func(e fsnotify.Event) {
+		timerMu.Lock()
+		defer timerMu.Unlock()
+
+		if timer != nil {
+			timer.Stop()
 		}
-	}
+		timer = time.AfterFunc(refreshDebounce, restart)
+	})
 }
 
-func killApp(cmd *exec.Cmd, sigchan chan os.Signal) {
-	sig := <-sigchan
-	fmt.Println("[greact] hope you developed something awesome! (received signal: ", sig, ")")
-	cmd.Process.Kill()
-	os.Remove("app")
-	os.Exit(0)
-}
+// ignoredServerPath reports whether a change to path doesn't affect the Go
+// app: only the Go files, go.mod and go.sum do, outside of the client, hidden
+// directories, node_modules and testdata
+func ignoredServerPath(p string, isDir bool) bool {
+	name := filepath.Base(p)
 
-func runApp(cmd *exec.Cmd) (*exec.Cmd, bool) {
-	cmd = exec.Command("./app")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Start(); err != nil {
-		log.Println("Error starting command: ", err)
-		return nil, true
-	}
-	return cmd, false
-}
+	if isDir {
+		if filepath.Clean(p) == filepath.Clean(config.GetConfig().ClientPath) {
+			return true
+		}
 
-func buildApp(cmd *exec.Cmd) bool {
-	cmd = exec.Command("go", "build", "-o", "app")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		log.Println("Error building command: ", err)
-		return true
+		return name != "." && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "testdata")
 	}
-	return false
+
+	return filepath.Ext(name) != ".go" && name != "go.mod" && name != "go.sum"
 }
 
-const refreshScript = `<script type="text/javascript">
-const socket = new WebSocket('ws://localhost:1501/ws');
+// refreshScriptTemplate is formatted with the websocket URL expression
+const refreshScriptTemplate = `<script type="text/javascript">
+const socket = new WebSocket(%s);
   socket.onmessage = function (event) {
-    if (event.data === 'refresh') {
+    const message = JSON.parse(event.data);
+    if (message.type === 'refresh') {
 	  console.log('Received refresh command from server. Reloading page...')
       window.location.reload();
+    } else if (message.type === 'hmr') {
+      window.__greactOverlay.hide();
+      window.dispatchEvent(new Event('greact:hmr'));
+    } else if (message.type === 'error') {
+      window.__greactOverlay.show(message.errors);
+    } else if (message.type === 'ok') {
+      window.__greactOverlay.hide();
     }
   }</script>`
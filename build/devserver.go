@@ -0,0 +1,91 @@
+package build
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/shynxe/greact/config"
+)
+
+const (
+	DefaultDevPort = config.DefaultDevPort
+	// devPortAttempts is the number of consecutive ports tried before letting the OS pick one
+	devPortAttempts = 10
+)
+
+// devPort is the port the dev websocket actually listens on
+var devPort int
+
+// devSettings returns the dev server host, port and public websocket URL
+func devSettings() (host string, port int, publicURL string) {
+	userConfig := config.GetConfig()
+
+	return userConfig.DevHost, userConfig.DevPort, userConfig.DevPublicURL
+}
+
+// listenDevSocket listens on the configured dev port, or on the next free one
+// when it is busy. A fixed public URL points at the configured port, so then
+// a busy port is an error.
+func listenDevSocket() (net.Listener, error) {
+	host, port, publicURL := devSettings()
+
+	listener, err := listenFreePort(host, port, publicURL != "")
+	if err != nil {
+		return nil, err
+	}
+
+	devPort = listener.Addr().(*net.TCPAddr).Port
+	if devPort != port {
+		fmt.Printf("port %d is busy, dev server listening on port %d\n", port, devPort)
+	}
+
+	return listener, nil
+}
+
+// listenFreePort listens on port, or unless it is fixed, on one of the next
+// ports or an OS picked one when it is busy
+func listenFreePort(host string, port int, fixed bool) (net.Listener, error) {
+	attempts := devPortAttempts
+	if fixed {
+		attempts = 1
+	}
+
+	var listener net.Listener
+	var err error
+	for i := 0; i < attempts && port+i <= 65535; i++ {
+		listener, err = net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port+i)))
+		if err == nil || !portBusy(err) {
+			break
+		}
+	}
+
+	// let the OS pick a free port
+	if err != nil && portBusy(err) && !fixed {
+		listener, err = net.Listen("tcp", net.JoinHostPort(host, "0"))
+	}
+
+	return listener, err
+}
+
+// portBusy reports whether listening failed because the port is in use, other
+// errors like a denied privileged port are returned as is
+func portBusy(err error) bool {
+	return errors.Is(err, errAddrInUse)
+}
+
+// devSocketURL returns the JavaScript expression of the dev websocket URL. By
+// default it connects to the host serving the page, over wss on https pages.
+func devSocketURL() string {
+	_, _, publicURL := devSettings()
+	if publicURL != "" {
+		return strconv.Quote(publicURL)
+	}
+
+	return fmt.Sprintf("(window.location.protocol === 'https:' ? 'wss://' : 'ws://') + window.location.hostname + ':%d/ws'", devPort)
+}
+
+func refreshScript() string {
+	return fmt.Sprintf(refreshScriptTemplate, devSocketURL())
+}
@@ -0,0 +1,95 @@
+package build
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/shynxe/greact/config"
+)
+
+func Test_listenDevSocket(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	busyPort := busy.Addr().(*net.TCPAddr).Port
+
+	t.Cleanup(config.Snapshot())
+	config.Override("devHost", "-dev-host", "127.0.0.1")
+	config.Override("devPort", "-dev-port", strconv.Itoa(busyPort))
+	defer config.ClearOverrides()
+
+	listener, err := listenDevSocket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if devPort == busyPort {
+		t.Errorf("listenDevSocket() listens on busy port %d", busyPort)
+	}
+
+	// a fixed public URL can't follow the port
+	config.Override("devPublicURL", "-dev-url", "wss://example.dev/ws")
+	if listener, err := listenDevSocket(); err == nil {
+		listener.Close()
+		t.Errorf("listenDevSocket() with a public URL on a busy port = nil error, want error")
+	}
+}
+
+func Test_portBusy(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	_, busyErr := net.Listen("tcp", busy.Addr().String())
+	if busyErr == nil {
+		t.Fatal("listening twice on the same port succeeded")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Test port in use", busyErr, true},
+		{"Test permission denied", &net.OpError{Op: "listen", Net: "tcp", Err: os.NewSyscallError("bind", syscall.EACCES)}, false},
+		{"Test unknown host", &net.OpError{Op: "listen", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "nowhere"}}, false},
+		{"Test other error", errors.New("failed"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := portBusy(tt.err); got != tt.want {
+				t.Errorf("portBusy(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_loadConfig_validatesFlags(t *testing.T) {
+	dir := t.TempDir()
+	configPath = filepath.Join(dir, "greact.env")
+	defer func() { configPath = "" }()
+
+	err := os.WriteFile(configPath, []byte("CLIENTPATH=./client\nSOURCEFOLDER=pages\nBUILDFOLDER=build\nSTATICFOLDER=static\nPUBLICPATH=/public/\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(config.Snapshot())
+	defer config.ClearOverrides()
+
+	if err := config.Override("devPublicURL", "-dev-url", "http://example.dev/ws"); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(); err == nil {
+		t.Errorf("loadConfig() with -dev-url http://example.dev/ws = nil error, want error")
+	}
+}
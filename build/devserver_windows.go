@@ -0,0 +1,8 @@
+//go:build windows
+
+package build
+
+import "syscall"
+
+// errAddrInUse is WSAEADDRINUSE, the errno of listening on a busy port
+var errAddrInUse error = syscall.Errno(10048)
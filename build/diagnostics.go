@@ -0,0 +1,203 @@
+package build
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/shynxe/greact/config"
+)
+
+const (
+	TargetClient = "client"
+	TargetServer = "server"
+)
+
+// Diagnostic is a single error reported by the bundler
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	switch {
+	case d.File == "":
+		return d.Message
+	case d.Line == 0:
+		return fmt.Sprintf("%s: %s", d.File, d.Message)
+	default:
+		return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+	}
+}
+
+// BuildError is returned when bundling the client or the server fails
+type BuildError struct {
+	// Target is either TargetClient or TargetServer
+	Target      string
+	Diagnostics []Diagnostic
+	// Output is the raw bundler output, if any
+	Output string
+	Err    error
+}
+
+func (e *BuildError) Error() string {
+	lines := []string{e.Target + " build failed"}
+	for _, d := range e.Diagnostics {
+		lines = append(lines, "  "+d.String())
+	}
+
+	if len(e.Diagnostics) == 0 && e.Err != nil {
+		lines[0] += ": " + e.Err.Error()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// BuildErrors holds the errors of the client and server builds when both fail
+type BuildErrors []error
+
+func (e BuildErrors) Error() string {
+	var messages []string
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// printBuildError prints the diagnostics of a failed build, or the raw
+// bundler output when none could be parsed
+func printBuildError(err error) {
+	var buildErrs BuildErrors
+	if errors.As(err, &buildErrs) {
+		for _, err := range buildErrs {
+			printBuildError(err)
+		}
+		return
+	}
+
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("[greact] error building %s bundle:\n", buildErr.Target)
+	for _, d := range buildErr.Diagnostics {
+		if d.File != "" {
+			location := d.File
+			if d.Line > 0 {
+				location += fmt.Sprintf(":%d:%d", d.Line, d.Column)
+			}
+			fmt.Println("  " + location)
+		}
+		fmt.Println("    " + strings.ReplaceAll(d.Message, "\n", "\n    "))
+	}
+
+	if len(buildErr.Diagnostics) == 0 {
+		if buildErr.Output != "" {
+			fmt.Println(buildErr.Output)
+		} else {
+			fmt.Println("  " + buildErr.Err.Error())
+		}
+	}
+}
+
+// webpackStats is the part of the webpack --json stats greact reads
+type webpackStats struct {
+	Errors []struct {
+		ModuleName string `json:"moduleName"`
+		Loc        string `json:"loc"`
+		Message    string `json:"message"`
+	} `json:"errors"`
+}
+
+var (
+	// webpackLoc matches the "line:column" start of a webpack location, e.g. "3:0-22"
+	webpackLoc = regexp.MustCompile(`^(\d+):(\d+)`)
+	// babelLoc matches the "(line:column)" of a babel syntax error
+	babelLoc = regexp.MustCompile(`\((\d+):(\d+)\)`)
+	// babelPrefix matches the "SyntaxError: /path/to/file.js: " prefix of a babel error
+	babelPrefix = regexp.MustCompile(`^\w*Error: [^:]+\.\w+: `)
+)
+
+// parseWebpackStats returns the errors of the webpack stats as diagnostics,
+// with the module paths joined to clientPath
+func parseWebpackStats(data []byte, clientPath string) ([]Diagnostic, error) {
+	var stats webpackStats
+	err := json.Unmarshal(data, &stats)
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnostics []Diagnostic
+	for _, statsErr := range stats.Errors {
+		d := Diagnostic{Message: webpackMessage(statsErr.Message)}
+
+		if statsErr.ModuleName != "" {
+			d.File = filepath.Join(clientPath, statsErr.ModuleName)
+		}
+
+		// webpack columns are 0-based, as are babel's
+		if match := webpackLoc.FindStringSubmatch(statsErr.Loc); match != nil {
+			d.Line, _ = strconv.Atoi(match[1])
+			d.Column, _ = strconv.Atoi(match[2])
+			d.Column++
+		} else if match := babelLoc.FindStringSubmatch(statsErr.Message); match != nil {
+			d.Line, _ = strconv.Atoi(match[1])
+			d.Column, _ = strconv.Atoi(match[2])
+			d.Column++
+		}
+
+		diagnostics = append(diagnostics, d)
+	}
+
+	return diagnostics, nil
+}
+
+// webpackMessage strips the loader noise and the code frame from a webpack error message
+func webpackMessage(message string) string {
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "Module build failed") {
+			continue
+		}
+
+		line = babelPrefix.ReplaceAllString(line, "")
+		return strings.TrimSpace(babelLoc.ReplaceAllString(line, ""))
+	}
+
+	return strings.TrimSpace(message)
+}
+
+// esbuildDiagnostics converts the esbuild messages to diagnostics
+func esbuildDiagnostics(messages []api.Message) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, msg := range messages {
+		d := Diagnostic{Message: msg.Text}
+		if msg.Location != nil {
+			// files are relative to the client path, which esbuild runs in
+			d.File = msg.Location.File
+			if msg.Location.Namespace != entryNamespace {
+				d.File = filepath.Join(config.GetConfig().ClientPath, msg.Location.File)
+			}
+			d.Line = msg.Location.Line
+			// esbuild columns are 0-based
+			d.Column = msg.Location.Column + 1
+		}
+		diagnostics = append(diagnostics, d)
+	}
+
+	return diagnostics
+}
@@ -0,0 +1,289 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shynxe/greact/config"
+	"github.com/shynxe/greact/renderer"
+)
+
+// minNodeVersion is the oldest major version of node the bundlers support
+const minNodeVersion = 16
+
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// checkResult is the outcome of a doctor check, with a hint to fix it
+type checkResult struct {
+	status  string
+	message string
+	hint    string
+}
+
+func pass(format string, a ...interface{}) checkResult {
+	return checkResult{status: checkPass, message: fmt.Sprintf(format, a...)}
+}
+
+func warn(hint string, format string, a ...interface{}) checkResult {
+	return checkResult{status: checkWarn, message: fmt.Sprintf(format, a...), hint: hint}
+}
+
+func fail(hint string, format string, a ...interface{}) checkResult {
+	return checkResult{status: checkFail, message: fmt.Sprintf(format, a...), hint: hint}
+}
+
+// doctorCheck diagnoses one part of the environment
+type doctorCheck struct {
+	name string
+	run  func() checkResult
+	// needsConfig checks are skipped when the config is invalid
+	needsConfig bool
+}
+
+var doctorChecks = []doctorCheck{
+	{name: "config", run: checkConfig},
+	{name: "go", run: checkGo},
+	{name: "node", run: checkNode},
+	{name: "package manager", run: checkPackageManager, needsConfig: true},
+	{name: "client", run: checkClient, needsConfig: true},
+	{name: "dependencies", run: checkDependencies, needsConfig: true},
+	{name: "template", run: checkTemplate, needsConfig: true},
+	{name: "build", run: checkBuild, needsConfig: true},
+}
+
+// Doctor is the main function of the doctor command. It exits with 1 when a
+// check fails.
+func Doctor(args []string) {
+	parseFlags("doctor", args)
+
+	if config.ActiveProfile() == "" {
+		config.Profile = defaultProfile()
+	}
+
+	failed, configValid := false, true
+	for _, check := range doctorChecks {
+		var result checkResult
+		if check.needsConfig && !configValid {
+			result = warn("", "skipped, the config is invalid")
+		} else {
+			result = check.run()
+		}
+
+		if check.name == "config" {
+			configValid = result.status != checkFail
+		}
+
+		fmt.Printf("[%s] %s: %s\n", result.status, check.name, result.message)
+		if result.hint != "" {
+			fmt.Printf("       %s\n", result.hint)
+		}
+
+		failed = failed || result.status == checkFail
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func checkConfig() checkResult {
+	if err := config.LoadConfig(configPath); err != nil {
+		return fail("run greact init to create a config file, or pass it with -c", "%v", err)
+	}
+
+	file := configPath
+	if file == "" {
+		file, _ = config.FindConfigFile(".")
+	}
+
+	validate := config.ValidateConfig
+	if !clientExists() {
+		validate = config.ValidateNewConfig
+	}
+	if err := validate(config.GetConfig()); err != nil {
+		return fail("fix the config, greact config show prints where each value comes from", "%s is invalid: %v", file, err)
+	}
+
+	return pass("%s is valid", file)
+}
+
+func checkGo() checkResult {
+	out, err := exec.Command("go", "version").Output()
+	if err != nil {
+		return fail("install go from https://go.dev/dl to use greact run and greact dev", "go not found: %v", err)
+	}
+
+	return pass("%s", strings.TrimSpace(string(out)))
+}
+
+func checkNode() checkResult {
+	out, err := exec.Command("node", "--version").Output()
+	if err != nil {
+		return fail("install node from https://nodejs.org, the pages are rendered with it", "node not found: %v", err)
+	}
+
+	version := strings.TrimSpace(string(out))
+	major, err := nodeMajorVersion(version)
+	if err != nil {
+		return warn("", "can't read the node version %s", version)
+	}
+	if major < minNodeVersion {
+		return fail(fmt.Sprintf("upgrade node to version %d or later", minNodeVersion), "node %s is too old", version)
+	}
+
+	return pass("node %s", version)
+}
+
+// nodeMajorVersion parses the major version of node --version, e.g. 18 for v18.12.1
+func nodeMajorVersion(version string) (int, error) {
+	major, _, _ := strings.Cut(strings.TrimPrefix(version, "v"), ".")
+	return strconv.Atoi(major)
+}
+
+func checkPackageManager() checkResult {
+	packageManager := detectPackageManager(config.GetConfig().ClientPath, ".")
+
+	if _, err := exec.LookPath(packageManager); err != nil {
+		return fail(fmt.Sprintf("install %s, or set packageManager in the config", packageManager), "%s not found", packageManager)
+	}
+
+	if config.GetConfig().Bundler != BundlerESBuild {
+		if _, err := exec.LookPath("npx"); err != nil {
+			return fail("install npm, the webpack bundler runs with npx", "npx not found")
+		}
+	}
+
+	return pass("%s", packageManager)
+}
+
+func checkClient() checkResult {
+	if !clientExists() {
+		return warn("run greact build to create it", "%s doesn't exist", config.GetConfig().ClientPath)
+	}
+
+	if err := clientValid(); err != nil {
+		return fail("install the client dependencies, or remove the client so that greact build creates it again", "%v", err)
+	}
+
+	return pass("%s", config.GetConfig().ClientPath)
+}
+
+// checkDependencies looks for every dependency the client is created with
+func checkDependencies() checkResult {
+	clientPath := config.GetConfig().ClientPath
+	if !clientExists() {
+		return warn("", "skipped, the client doesn't exist")
+	}
+
+	required := map[string]string{}
+	for name, version := range dependencies {
+		required[name] = version
+	}
+	if config.GetConfig().Bundler != BundlerESBuild {
+		for name, version := range devDependencies {
+			required[name] = version
+		}
+	}
+	// only dev -hmr needs them, older clients were created without them
+	for _, name := range hmrDependencies {
+		delete(required, name)
+	}
+
+	var missing []string
+	for name := range required {
+		if _, err := os.Stat(filepath.Join(clientPath, "node_modules", name, "package.json")); err != nil {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+
+	if len(missing) > 0 {
+		packageManager := detectPackageManager(clientPath, ".")
+		return fail(fmt.Sprintf("run %s install in %s", packageManager, clientPath), "missing %s", strings.Join(missing, ", "))
+	}
+
+	if config.GetConfig().Bundler != BundlerESBuild {
+		var missingHMR []string
+		for _, name := range hmrDependencies {
+			if _, err := os.Stat(filepath.Join(clientPath, "node_modules", name, "package.json")); err != nil {
+				missingHMR = append(missingHMR, name)
+			}
+		}
+
+		if len(missingHMR) > 0 {
+			return warn("install them as dev dependencies of the client to use greact dev -hmr", "%d packages installed, missing %s for hot module replacement", len(required), strings.Join(missingHMR, ", "))
+		}
+	}
+
+	return pass("%d packages installed", len(required))
+}
+
+func checkTemplate() checkResult {
+	userTemplate := config.GetConfig().Template
+	if userTemplate == "" {
+		return pass("default template")
+	}
+
+	path := filepath.Join(config.GetConfig().ClientPath, userTemplate)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fail("create the template, or unset template in the config", "%v", err)
+	}
+
+	if _, err := renderer.ParseTemplate(path, string(data)); err != nil {
+		return fail("fix the template slots, see the README", "%v", err)
+	}
+
+	return pass("%s", path)
+}
+
+// checkBuild compares the client with the inputs of the last build
+// buildModes are the -dev and -hmr flags the last build may have run with,
+// the cache keys depend on them
+var buildModes = []struct{ dev, hmr bool }{{false, false}, {true, false}, {true, true}}
+
+func checkBuild() checkResult {
+	if !clientExists() {
+		return warn("", "skipped, the client doesn't exist")
+	}
+
+	defer func(dev, hmr bool) { devMode, hmrMode = dev, hmr }(devMode, hmrMode)
+
+	cache := loadBuildCache()
+	var stale []string
+	for i, mode := range buildModes {
+		devMode, hmrMode = mode.dev, mode.hmr
+
+		// the generated entries are missing before the first build
+		keys, err := buildCacheKeys()
+		if err != nil {
+			return warn("run greact build", "never built")
+		}
+
+		var modeStale []string
+		for _, target := range []string{TargetClient, TargetServer} {
+			if !cache.fresh(target, keys[target]) {
+				modeStale = append(modeStale, target)
+			}
+		}
+
+		if len(modeStale) == 0 {
+			return pass("up to date")
+		}
+		// the targets are reported as for greact build
+		if i == 0 {
+			stale = modeStale
+		}
+	}
+
+	return warn("run greact build", "the %s build is out of date", strings.Join(stale, " and "))
+}
@@ -0,0 +1,145 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shynxe/greact/config"
+)
+
+func Test_nodeMajorVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    int
+		wantErr bool
+	}{
+		{"v18.12.1", 18, false},
+		{"v20.0.0", 20, false},
+		{"14", 14, false},
+		{"unknown", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := nodeMajorVersion(tt.version)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("nodeMajorVersion(%s) error = %v, wantErr %v", tt.version, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("nodeMajorVersion(%s) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func Test_checkDependencies(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "greact.env")
+	err := os.WriteFile(configFile, []byte("CLIENTPATH=./client\nSOURCEFOLDER=pages\nBUILDFOLDER=build\nSTATICFOLDER=static\nPUBLICPATH=/public/\nBUNDLER=esbuild\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadConfig(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	install := func(name string) {
+		path := filepath.Join(dir, "client", "node_modules", name, "package.json")
+		os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		os.WriteFile(path, []byte("{}"), 0644)
+	}
+
+	install("react")
+	if got := checkDependencies(); got.status != checkFail {
+		t.Errorf("checkDependencies() without react-dom = %v, want %v", got, checkFail)
+	}
+
+	// esbuild only needs the runtime dependencies
+	install("react-dom")
+	if got := checkDependencies(); got.status != checkPass {
+		t.Errorf("checkDependencies() = %v, want %v", got, checkPass)
+	}
+}
+
+func Test_checkDependencies_hmr(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "greact.env")
+	err := os.WriteFile(configFile, []byte("CLIENTPATH=./client\nSOURCEFOLDER=pages\nBUILDFOLDER=build\nSTATICFOLDER=static\nPUBLICPATH=/public/\nBUNDLER=webpack\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(config.Snapshot())
+	if err := config.LoadConfig(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	install := func(name string) {
+		path := filepath.Join(dir, "client", "node_modules", name, "package.json")
+		os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		os.WriteFile(path, []byte("{}"), 0644)
+	}
+
+	hmr := map[string]bool{}
+	for _, name := range hmrDependencies {
+		hmr[name] = true
+	}
+	for name := range dependencies {
+		install(name)
+	}
+	for name := range devDependencies {
+		if !hmr[name] {
+			install(name)
+		}
+	}
+
+	// a client created before hot module replacement still builds
+	if got := checkDependencies(); got.status != checkWarn {
+		t.Errorf("checkDependencies() without the HMR dependencies = %v, want %v", got, checkWarn)
+	}
+
+	for _, name := range hmrDependencies {
+		install(name)
+	}
+	if got := checkDependencies(); got.status != checkPass {
+		t.Errorf("checkDependencies() = %v, want %v", got, checkPass)
+	}
+}
+
+func Test_checkBuild(t *testing.T) {
+	dir := t.TempDir()
+	clientPath := filepath.Join(dir, "client")
+	configFile := filepath.Join(dir, "greact.env")
+	err := os.WriteFile(configFile, []byte("CLIENTPATH=./client\nSOURCEFOLDER=pages\nBUILDFOLDER=build\nSTATICFOLDER=static\nPUBLICPATH=/public/\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(config.Snapshot())
+	if err := config.LoadConfig(configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"pages/index.js", "build/.greact-hydrater.js", "build/.greact-renderer.js", "build/render.js", "static/manifest.json"} {
+		path := filepath.Join(clientPath, name)
+		os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		os.WriteFile(path, []byte(name), 0644)
+	}
+
+	// built by greact dev -hmr
+	devMode, hmrMode = true, true
+	keys, err := buildCacheKeys()
+	devMode, hmrMode = false, false
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (buildCache{TargetClient: keys[TargetClient], TargetServer: keys[TargetServer]}).save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := checkBuild(); got.status != checkPass {
+		t.Errorf("checkBuild() after a dev build = %v, want %v", got, checkPass)
+	}
+
+	os.WriteFile(filepath.Join(clientPath, "pages/index.js"), []byte("changed"), 0644)
+	if got := checkBuild(); got.status != checkWarn {
+		t.Errorf("checkBuild() after a change = %v, want %v", got, checkWarn)
+	}
+}
@@ -0,0 +1,380 @@
+package build
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/shynxe/greact/config"
+	"github.com/shynxe/greact/manifest"
+)
+
+// entryNamespace is the esbuild namespace of the generated per-page entries
+const entryNamespace = "greact-entry"
+
+// esbuildBundler bundles in-process through the esbuild Go API. The build
+// contexts are kept between builds, so rebuilds in dev mode are incremental.
+type esbuildBundler struct {
+	pages  []string
+	client api.BuildContext
+	server api.BuildContext
+}
+
+type esbuildMetafile struct {
+	Outputs map[string]struct {
+		EntryPoint string `json:"entryPoint"`
+		CSSBundle  string `json:"cssBundle"`
+		Imports    []struct {
+			Path string `json:"path"`
+			Kind string `json:"kind"`
+		} `json:"imports"`
+	} `json:"outputs"`
+}
+
+func (b *esbuildBundler) BuildClient(ctx context.Context) error {
+	// the entry points are fixed per context, so recreate it when pages are added or removed
+	pages := getSourcePageNames()
+	if b.client == nil || !reflect.DeepEqual(pages, b.pages) {
+		if b.client != nil {
+			b.client.Dispose()
+			b.client = nil
+		}
+
+		options, err := clientBuildOptions(pages)
+		if err != nil {
+			return err
+		}
+
+		ctx, ctxErr := api.Context(options)
+		if ctxErr != nil {
+			return esbuildError(TargetClient, ctxErr.Errors)
+		}
+		b.client, b.pages = ctx, pages
+	}
+
+	result := rebuild(ctx, b.client)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(result.Errors) > 0 {
+		return esbuildError(TargetClient, result.Errors)
+	}
+
+	integrity := map[string]string{}
+	for _, file := range result.OutputFiles {
+		err := os.MkdirAll(filepath.Dir(file.Path), os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+		err = os.WriteFile(file.Path, file.Contents, 0644)
+		if err != nil {
+			return err
+		}
+
+		sum := sha512.Sum384(file.Contents)
+		integrity[file.Path] = "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+	}
+
+	// clean the static folder like webpack's output.clean does, but keep the
+	// folder itself: the dev watcher and the app serving it hold on to it
+	err := removeStaleOutputs(config.StaticPath, integrity)
+	if err != nil {
+		return err
+	}
+
+	m, err := esbuildManifest(result.Metafile, integrity)
+	if err != nil {
+		return err
+	}
+
+	return manifest.Write(filepath.Join(config.StaticPath, manifest.FileName), m)
+}
+
+// removeStaleOutputs removes the files of dir which aren't in outputs, except
+// the manifest which is rewritten after them
+func removeStaleOutputs(dir string, outputs map[string]string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || path == filepath.Join(dir, manifest.FileName) {
+			return nil
+		}
+		if _, ok := outputs[path]; ok {
+			return nil
+		}
+
+		return os.Remove(path)
+	})
+}
+
+func (b *esbuildBundler) BuildServer(ctx context.Context) error {
+	if b.server == nil {
+		options, err := serverBuildOptions()
+		if err != nil {
+			return err
+		}
+
+		ctx, ctxErr := api.Context(options)
+		if ctxErr != nil {
+			return esbuildError(TargetServer, ctxErr.Errors)
+		}
+		b.server = ctx
+	}
+
+	result := rebuild(ctx, b.server)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(result.Errors) > 0 {
+		return esbuildError(TargetServer, result.Errors)
+	}
+
+	return nil
+}
+
+func (b *esbuildBundler) Close() error {
+	if b.client != nil {
+		b.client.Dispose()
+		b.client = nil
+	}
+
+	if b.server != nil {
+		b.server.Dispose()
+		b.server = nil
+	}
+
+	return nil
+}
+
+// rebuild runs the build, cancelling it when ctx is cancelled
+func rebuild(ctx context.Context, buildCtx api.BuildContext) api.BuildResult {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			buildCtx.Cancel()
+		case <-done:
+		}
+	}()
+
+	return buildCtx.Rebuild()
+}
+
+func clientBuildOptions(pages []string) (api.BuildOptions, error) {
+	clientPath, err := filepath.Abs(config.GetConfig().ClientPath)
+	if err != nil {
+		return api.BuildOptions{}, err
+	}
+
+	staticPath, err := filepath.Abs(config.StaticPath)
+	if err != nil {
+		return api.BuildOptions{}, err
+	}
+
+	var entryPoints []api.EntryPoint
+	for _, page := range pages {
+		entryPoints = append(entryPoints, api.EntryPoint{
+			InputPath:  entryNamespace + ":" + page,
+			OutputPath: page,
+		})
+	}
+
+	return api.BuildOptions{
+		AbsWorkingDir:       clientPath,
+		EntryPointsAdvanced: entryPoints,
+		Bundle:              true,
+		Splitting:           true,
+		Format:              api.FormatESModule,
+		Platform:            api.PlatformBrowser,
+		Outdir:              staticPath,
+		EntryNames:          "[name].[hash]",
+		ChunkNames:          "chunk.[hash]",
+		AssetNames:          "[name].[hash]",
+		Loader:              map[string]api.Loader{".js": api.LoaderJSX},
+		Define:              map[string]string{"process.env.NODE_ENV": strconv.Quote(nodeEnv())},
+		MinifyWhitespace:    !devMode,
+		MinifyIdentifiers:   !devMode,
+		MinifySyntax:        !devMode,
+		Metafile:            true,
+		Write:               false,
+		LogLevel:            api.LogLevelSilent,
+		Plugins:             []api.Plugin{entryPlugin(clientPath)},
+	}, nil
+}
+
+func serverBuildOptions() (api.BuildOptions, error) {
+	clientPath, err := filepath.Abs(config.GetConfig().ClientPath)
+	if err != nil {
+		return api.BuildOptions{}, err
+	}
+
+	buildPath, err := filepath.Abs(config.BuildPath)
+	if err != nil {
+		return api.BuildOptions{}, err
+	}
+
+	return api.BuildOptions{
+		AbsWorkingDir: clientPath,
+		EntryPoints:   []string{filepath.Join(buildPath, ".greact-renderer.js")},
+		Bundle:        true,
+		Format:        api.FormatCommonJS,
+		Platform:      api.PlatformNode,
+		Outfile:       filepath.Join(buildPath, "render.js"),
+		Loader:        map[string]api.Loader{".js": api.LoaderJSX},
+		Define:        map[string]string{"process.env.NODE_ENV": strconv.Quote(nodeEnv())},
+		Write:         true,
+		LogLevel:      api.LogLevelSilent,
+	}, nil
+}
+
+// entryPlugin resolves the per-page entries, which import the hydrater and the
+// page and expose both as globals for the hydration script, like webpack's UMD output
+func entryPlugin(clientPath string) api.Plugin {
+	return api.Plugin{
+		Name: "greact-entry",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: "^" + entryNamespace + ":"},
+				func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+					return api.OnResolveResult{
+						Path:      strings.TrimPrefix(args.Path, entryNamespace+":"),
+						Namespace: entryNamespace,
+					}, nil
+				})
+
+			build.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: entryNamespace},
+				func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					hydrater, err := filepath.Abs(filepath.Join(config.BuildPath, ".greact-hydrater.js"))
+					if err != nil {
+						return api.OnLoadResult{}, err
+					}
+
+					page, err := filepath.Abs(filepath.Join(config.SourcePath, args.Path+".js"))
+					if err != nil {
+						return api.OnLoadResult{}, err
+					}
+
+					contents := fmt.Sprintf(
+						"import * as hydrate from %s;\nimport * as page from %s;\nwindow.hydrate = hydrate;\nwindow[%s] = page;\n",
+						strconv.Quote(hydrater), strconv.Quote(page), strconv.Quote(args.Path),
+					)
+
+					return api.OnLoadResult{
+						Contents:   &contents,
+						ResolveDir: clientPath,
+						Loader:     api.LoaderJS,
+					}, nil
+				})
+		},
+	}
+}
+
+// esbuildManifest maps every page entry to its chunks, static imports first
+func esbuildManifest(metafile string, integrity map[string]string) (manifest.Manifest, error) {
+	var meta esbuildMetafile
+	err := json.Unmarshal([]byte(metafile), &meta)
+	if err != nil {
+		return manifest.Manifest{}, err
+	}
+
+	clientPath, err := filepath.Abs(config.GetConfig().ClientPath)
+	if err != nil {
+		return manifest.Manifest{}, err
+	}
+
+	staticPath, err := filepath.Abs(config.StaticPath)
+	if err != nil {
+		return manifest.Manifest{}, err
+	}
+
+	asset := func(output string) (manifest.Asset, error) {
+		path := filepath.Join(clientPath, output)
+		file, err := filepath.Rel(staticPath, path)
+		if err != nil {
+			return manifest.Asset{}, err
+		}
+
+		return manifest.Asset{File: filepath.ToSlash(file), Integrity: integrity[path]}, nil
+	}
+
+	m := manifest.Manifest{
+		PublicPath: config.GetConfig().PublicPath,
+		Pages:      map[string]manifest.Page{},
+	}
+
+	for output, out := range meta.Outputs {
+		if !strings.HasPrefix(out.EntryPoint, entryNamespace+":") {
+			continue
+		}
+
+		// walk the static imports so every chunk comes before its importer
+		var files []string
+		seen := map[string]bool{}
+		var walk func(output string)
+		walk = func(output string) {
+			if seen[output] {
+				return
+			}
+			seen[output] = true
+
+			for _, imported := range meta.Outputs[output].Imports {
+				if imported.Kind == "import-statement" {
+					walk(imported.Path)
+				}
+			}
+			files = append(files, output)
+		}
+		walk(output)
+
+		page := manifest.Page{JS: []manifest.Asset{}, CSS: []manifest.Asset{}, Module: true}
+		for _, file := range files {
+			a, err := asset(file)
+			if err != nil {
+				return manifest.Manifest{}, err
+			}
+			page.JS = append(page.JS, a)
+		}
+
+		if out.CSSBundle != "" {
+			a, err := asset(out.CSSBundle)
+			if err != nil {
+				return manifest.Manifest{}, err
+			}
+			page.CSS = append(page.CSS, a)
+		}
+
+		m.Pages[strings.TrimPrefix(out.EntryPoint, entryNamespace+":")] = page
+	}
+
+	return m, nil
+}
+
+func esbuildError(target string, messages []api.Message) error {
+	return &BuildError{
+		Target:      target,
+		Diagnostics: esbuildDiagnostics(messages),
+		Err:         errors.New("esbuild failed"),
+	}
+}
+
+func nodeEnv() string {
+	if devMode {
+		return "development"
+	}
+	return "production"
+}
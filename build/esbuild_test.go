@@ -0,0 +1,33 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shynxe/greact/manifest"
+)
+
+func Test_removeStaleOutputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"index-NEW.js", "index-OLD.js", "chunks/old.js", manifest.FileName} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.ModePerm)
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+
+	outputs := map[string]string{filepath.Join(dir, "index-NEW.js"): ""}
+	if err := removeStaleOutputs(dir, outputs); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{"index-NEW.js": true, "index-OLD.js": false, "chunks/old.js": false, manifest.FileName: true, ".": true} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", name, err == nil, want)
+		}
+	}
+
+	// the static folder is missing before the first build
+	if err := removeStaleOutputs(filepath.Join(dir, "missing"), outputs); err != nil {
+		t.Errorf("removeStaleOutputs() of a missing folder = %v", err)
+	}
+}
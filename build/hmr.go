@@ -0,0 +1,193 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/shynxe/greact/config"
+)
+
+// hmrDependencies must be installed in the client for hot module replacement
+var hmrDependencies = []string{
+	"react-refresh",
+	"@pmmmwh/react-refresh-webpack-plugin",
+}
+
+// hmrStatsFileName is written by the webpack config after every compilation
+// of the watcher, in the build folder
+const hmrStatsFileName = ".greact-hmr-stats.json"
+
+// watchClient keeps a webpack --watch process compiling the client, which
+// emits the hot updates the dev client applies with React Fast Refresh. The
+// process is only restarted when its config changes, i.e. when pages are
+// added or removed. It returns the errors of the last compilation, and the
+// errors of the next ones are pushed to the browser as they happen.
+func (b *webpackBundler) watchClient(ctx context.Context) error {
+	for _, dependency := range hmrDependencies {
+		if _, err := os.Stat(filepath.Join(config.GetConfig().ClientPath, "node_modules", dependency)); os.IsNotExist(err) {
+			return fmt.Errorf("hot module replacement needs %s, install it as a dev dependency of the client", dependency)
+		}
+	}
+
+	err := os.WriteFile(filepath.Join(config.BuildPath, ".greact-hmr-client.js"), []byte(hmrClient), 0644)
+	if err != nil {
+		return err
+	}
+
+	configPath := filepath.Join(config.GetConfig().ClientPath, "webpack.config.js")
+	previous, _ := os.ReadFile(configPath)
+
+	err = createWebpackConfig()
+	if err != nil {
+		return fmt.Errorf("error creating webpack config: %w", err)
+	}
+
+	current, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	statsPath := filepath.Join(config.BuildPath, hmrStatsFileName)
+
+	if b.watcherRunning() && bytes.Equal(previous, current) {
+		return readHMRStats(statsPath)
+	}
+
+	b.stopWatcher()
+
+	// the stats are written at the end of every compilation, even a failed one
+	os.Remove(statsPath)
+
+	cmd := exec.Command("npx", "webpack", "--mode", "development", "--watch")
+	cmd.Dir = config.GetConfig().ClientPath
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	setProcessGroup(cmd)
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	b.watcher, b.watcherDone = cmd, done
+
+	// wait for the first compilation
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.stopWatcher()
+			return ctx.Err()
+		case <-done:
+			return &BuildError{Target: TargetClient, Err: fmt.Errorf("webpack --watch exited: %v", cmd.ProcessState)}
+		case <-ticker.C:
+			info, err := os.Stat(statsPath)
+			if err != nil {
+				continue
+			}
+
+			go watchHMRStats(statsPath, info.ModTime(), done)
+			return readHMRStats(statsPath)
+		}
+	}
+}
+
+// watchHMRStats reports the result of every compilation after the one at
+// since, until done is closed. Webpack recompiles on its own, so these don't
+// go through build.
+func watchHMRStats(statsPath string, since time.Time, done chan struct{}) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(statsPath)
+			if err != nil || !info.ModTime().After(since) {
+				continue
+			}
+			since = info.ModTime()
+
+			reportTargetResult(TargetClient, readHMRStats(statsPath))
+		}
+	}
+}
+
+// readHMRStats returns the errors of the last compilation of the watcher
+func readHMRStats(statsPath string) error {
+	data, err := os.ReadFile(statsPath)
+	if err != nil {
+		return &BuildError{Target: TargetClient, Err: err}
+	}
+
+	diagnostics, err := parseWebpackStats(data, config.GetConfig().ClientPath)
+	if err != nil {
+		return &BuildError{Target: TargetClient, Err: fmt.Errorf("invalid webpack stats: %w", err)}
+	}
+	if len(diagnostics) > 0 {
+		return &BuildError{Target: TargetClient, Diagnostics: diagnostics, Err: fmt.Errorf("webpack found %d errors", len(diagnostics))}
+	}
+
+	return nil
+}
+
+func (b *webpackBundler) watcherRunning() bool {
+	if b.watcher == nil {
+		return false
+	}
+
+	select {
+	case <-b.watcherDone:
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *webpackBundler) stopWatcher() {
+	if b.watcher == nil {
+		return
+	}
+
+	if b.watcherRunning() {
+		killProcessGroup(b.watcher)
+		<-b.watcherDone
+	}
+
+	b.watcher, b.watcherDone = nil, nil
+}
+
+func (b *webpackBundler) Close() error {
+	b.stopWatcher()
+	return nil
+}
+
+// hmrClient applies the hot updates when the dev client announces a rebuild,
+// and reloads the page when they can't be applied
+const hmrClient = `if (module.hot) {
+  window.addEventListener('greact:hmr', function () {
+    if (module.hot.status() !== 'idle') {
+      return;
+    }
+
+    module.hot.check(true).catch(function (err) {
+      console.warn('[greact] hot update failed, reloading the page', err);
+      window.location.reload();
+    });
+  });
+}
+`
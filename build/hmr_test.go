@@ -0,0 +1,47 @@
+package build
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_reportTargetResult(t *testing.T) {
+	defer reportBuildResult(nil)
+
+	dir := t.TempDir()
+	statsPath := filepath.Join(dir, hmrStatsFileName)
+	serverErr := &BuildError{Target: TargetServer, Err: errors.New("server")}
+
+	tests := []struct {
+		name       string
+		stats      string
+		wantType   string
+		wantErrors int
+	}{
+		{name: "client errors are added to the server ones", stats: `{"errors":[{"message":"SyntaxError: Unexpected token","moduleName":"./src/index.js","loc":"3:4"}]}`, wantType: "error", wantErrors: 2},
+		{name: "fixed client keeps the server errors", stats: `{"errors":[]}`, wantType: "error", wantErrors: 1},
+	}
+
+	reportBuildResult(BuildErrors{serverErr})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.WriteFile(statsPath, []byte(tt.stats), 0644)
+
+			reportTargetResult(TargetClient, readHMRStats(statsPath))
+
+			got := currentBuildResultMessage()
+			if got.Type != tt.wantType || len(got.Errors) != tt.wantErrors {
+				t.Errorf("reportTargetResult() = %s with %d errors, want %s with %d", got.Type, len(got.Errors), tt.wantType, tt.wantErrors)
+			}
+		})
+	}
+
+	// without the server errors the overlay is hidden
+	reportBuildResult(nil)
+	reportTargetResult(TargetClient, readHMRStats(statsPath))
+	if got := currentBuildResultMessage(); got.Type != "ok" {
+		t.Errorf("reportTargetResult() = %s, want ok", got.Type)
+	}
+}
@@ -0,0 +1,163 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait is the time allowed to write a message to a client
+	writeWait = 10 * time.Second
+	// pongWait is the time allowed to read the next pong from a client
+	pongWait = 60 * time.Second
+	// pingPeriod must be shorter than pongWait
+	pingPeriod = pongWait * 9 / 10
+	// sendBuffer is the number of messages queued per client before it is dropped
+	sendBuffer = 16
+)
+
+// hub tracks the connected dev clients (one per browser tab) and broadcasts
+// messages to all of them
+type hub struct {
+	mu       sync.Mutex
+	clients  map[*hubClient]bool
+	upgrader websocket.Upgrader
+	// welcome returns the message sent to every client as it connects
+	welcome func() devMessage
+}
+
+type hubClient struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+func newHub(welcome func() devMessage) *hub {
+	return &hub{
+		clients: map[*hubClient]bool{},
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		welcome: welcome,
+	}
+}
+
+// ServeHTTP upgrades the request and registers the client until it disconnects
+func (h *hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Println("Error upgrading websocket:", err)
+		return
+	}
+
+	client := &hubClient{conn: conn, send: make(chan []byte, sendBuffer)}
+
+	if h.welcome != nil {
+		if data, err := json.Marshal(h.welcome()); err == nil {
+			client.send <- data
+		}
+	}
+
+	h.mu.Lock()
+	h.clients[client] = true
+	h.mu.Unlock()
+
+	go h.writePump(client)
+	h.readPump(client)
+}
+
+// Broadcast sends the message to every connected client. Clients that can't
+// keep up are disconnected rather than blocking the others.
+func (h *hub) Broadcast(message devMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		fmt.Println("Error encoding websocket message:", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		select {
+		case client.send <- data:
+		default:
+			h.removeLocked(client)
+		}
+	}
+}
+
+// Len returns the number of connected clients
+func (h *hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.clients)
+}
+
+func (h *hub) remove(client *hubClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.removeLocked(client)
+}
+
+func (h *hub) removeLocked(client *hubClient) {
+	if h.clients[client] {
+		delete(h.clients, client)
+		close(client.send)
+	}
+}
+
+// readPump discards the client messages, and notices disconnects and missed heartbeats
+func (h *hub) readPump(client *hubClient) {
+	defer func() {
+		h.remove(client)
+		client.conn.Close()
+	}()
+
+	client.conn.SetReadDeadline(time.Now().Add(pongWait))
+	client.conn.SetPongHandler(func(string) error {
+		return client.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		if _, _, err := client.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump is the only writer of the connection, sending the queued messages and the heartbeats
+func (h *hub) writePump(client *hubClient) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// the hub removed the client
+				client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			if err := client.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package build
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func dialHub(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) devMessage {
+	t.Helper()
+
+	var message devMessage
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatal(err)
+	}
+
+	return message
+}
+
+// waitForClients waits until the hub has registered n clients
+func waitForClients(t *testing.T, h *hub, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for h.Len() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("hub has %d clients, want %d", h.Len(), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_hub_Broadcast(t *testing.T) {
+	h := newHub(nil)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	first := dialHub(t, server)
+	defer first.Close()
+	second := dialHub(t, server)
+	defer second.Close()
+	waitForClients(t, h, 2)
+
+	want := devMessage{Type: "refresh"}
+	h.Broadcast(want)
+
+	for _, conn := range []*websocket.Conn{first, second} {
+		if got := readMessage(t, conn); !reflect.DeepEqual(got, want) {
+			t.Errorf("readMessage() = %v, want %v", got, want)
+		}
+	}
+}
+
+func Test_hub_disconnect(t *testing.T) {
+	h := newHub(nil)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	first := dialHub(t, server)
+	second := dialHub(t, server)
+	defer second.Close()
+	waitForClients(t, h, 2)
+
+	first.Close()
+	waitForClients(t, h, 1)
+
+	// the remaining client still gets the broadcasts
+	want := devMessage{Type: "refresh"}
+	h.Broadcast(want)
+	if got := readMessage(t, second); !reflect.DeepEqual(got, want) {
+		t.Errorf("readMessage() = %v, want %v", got, want)
+	}
+}
+
+func Test_hub_noClients(t *testing.T) {
+	h := newHub(nil)
+
+	// must not panic without any open tab
+	h.Broadcast(devMessage{Type: "refresh"})
+}
+
+func Test_hub_welcome(t *testing.T) {
+	want := devMessage{Type: "error", Errors: []overlayError{{Target: TargetClient, Message: "failed"}}}
+	h := newHub(func() devMessage { return want })
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	conn := dialHub(t, server)
+	defer conn.Close()
+
+	if got := readMessage(t, conn); !reflect.DeepEqual(got, want) {
+		t.Errorf("readMessage() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,283 @@
+package build
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/shynxe/greact/config"
+)
+
+const (
+	RouterNetHTTP = "net/http"
+	RouterGin     = "gin"
+)
+
+// serverTemplates are the Go servers init scaffolds, by router
+var serverTemplates = map[string]string{
+	RouterNetHTTP: netHTTPServerTemplate,
+	RouterGin:     ginServerTemplate,
+}
+
+// Init is the main function of the init command. It creates the config file
+// and, with -scaffold, a runnable project: the client with a sample page, a
+// Go server rendering it and the .gitignore entries of the generated files.
+func Init(args []string) error {
+	var (
+		scaffold bool
+		router   string
+		module   string
+	)
+
+	flagSet := flag.NewFlagSet("init", flag.ExitOnError)
+	flagSet.BoolVar(&scaffold, "scaffold", false, "also create the client, a Go server and the .gitignore entries")
+	flagSet.StringVar(&router, "router", RouterNetHTTP, "router of the Go server, net/http or gin")
+	flagSet.BoolVar(&keepPartial, "keep-partial", false, "keep the partially created client when its creation fails, for debugging")
+	flagSet.StringVar(&module, "module", "", "module path of the go.mod created when there is none (default the directory name)")
+
+	fileName, err := config.InitConfigFlags(flagSet, args)
+	if err != nil {
+		return err
+	}
+
+	if !scaffold {
+		return nil
+	}
+
+	if _, ok := serverTemplates[router]; !ok {
+		return fmt.Errorf("router must be net/http or gin, got %s", router)
+	}
+
+	if err := config.LoadConfig(fileName); err != nil {
+		return err
+	}
+
+	return scaffoldProject(router, module)
+}
+
+func scaffoldProject(router string, module string) error {
+	if clientExists() {
+		fmt.Println("client exists, skipping it")
+	} else if err := scaffoldClient(); err != nil {
+		return err
+	}
+
+	if err := scaffoldServer(router, module); err != nil {
+		return err
+	}
+
+	return scaffoldGitignore()
+}
+
+// scaffoldServer writes the main.go of the Go server in the Go directory, and
+// a go.mod when there is none. An existing main.go is left alone.
+func scaffoldServer(router string, module string) error {
+	target, err := goTargetSettings()
+	if err != nil {
+		return err
+	}
+	dir := target.Dir
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	mainPath := filepath.Join(dir, "main.go")
+
+	if _, err := os.Stat(mainPath); err == nil {
+		fmt.Println("main.go exists, skipping the Go server")
+		return nil
+	}
+
+	t, err := template.New("main.go").Parse(serverTemplates[router])
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(mainPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = t.Execute(f, struct{ Address string }{Address: serverAddress()})
+	if err != nil {
+		return err
+	}
+	fmt.Println("created", mainPath)
+
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); os.IsNotExist(err) {
+		if module == "" {
+			module = filepath.Base(dir)
+		}
+
+		if err := runGo(dir, "mod", "init", module); err != nil {
+			return err
+		}
+	}
+
+	// fetching the dependencies needs the network, the project is usable without it
+	if err := runGo(dir, "mod", "tidy"); err != nil {
+		fmt.Println("couldn't add the Go dependencies, run go mod tidy:", err)
+	}
+
+	return nil
+}
+
+// serverAddress is the address of the scaffolded server, the one the dev proxy targets
+func serverAddress() string {
+	appURL, _, _ := proxySettings()
+	if u, err := url.Parse(appURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+
+	return "localhost:8080"
+}
+
+func runGo(dir string, args ...string) error {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// scaffoldGitignore adds the generated files to the .gitignore next to the
+// config, skipping the entries it already has
+func scaffoldGitignore() error {
+	path := filepath.Join(config.Dir, ".gitignore")
+
+	clientPath, err := filepath.Rel(config.Dir, config.GetConfig().ClientPath)
+	if err != nil || strings.HasPrefix(clientPath, "..") {
+		fmt.Println("the client is outside of the project, skipping .gitignore")
+		return nil
+	}
+	client := "/" + filepath.ToSlash(clientPath) + "/"
+
+	entries := []string{
+		client + "node_modules/",
+		client + config.GetConfig().BuildFolder + "/",
+		client + config.GetConfig().StaticFolder + "/",
+	}
+	var generated []string
+	for name := range generatedFiles {
+		generated = append(generated, client+name)
+	}
+	sort.Strings(generated)
+	entries = append(entries, generated...)
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := map[string]bool{}
+	for _, line := range strings.Split(string(existing), "\n") {
+		lines[strings.TrimSpace(line)] = true
+	}
+
+	var missing []string
+	for _, entry := range entries {
+		if !lines[entry] {
+			missing = append(missing, entry)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString("# greact\n")
+	for _, entry := range missing {
+		b.WriteString(entry + "\n")
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(b.String())
+	return err
+}
+
+const netHTTPServerTemplate = `package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/shynxe/greact/config"
+	"github.com/shynxe/greact/renderer"
+)
+
+func main() {
+	// an empty path finds the greact config of the current directory
+	if err := config.LoadConfig(""); err != nil {
+		log.Fatal(err)
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		props := map[string]interface{}{
+			"name": "World",
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(renderer.RenderPage("index", props)))
+	})
+
+	// serve the page chunks
+	publicPath := config.GetConfig().PublicPath
+	http.Handle(publicPath, http.StripPrefix(publicPath, http.FileServer(http.Dir(config.StaticPath))))
+
+	log.Println("listening on {{.Address}}")
+	log.Fatal(http.ListenAndServe("{{.Address}}", nil))
+}
+`
+
+const ginServerTemplate = `package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shynxe/greact/config"
+	"github.com/shynxe/greact/renderer"
+)
+
+func main() {
+	// an empty path finds the greact config of the current directory
+	if err := config.LoadConfig(""); err != nil {
+		log.Fatal(err)
+	}
+
+	r := gin.Default()
+
+	r.GET("/", func(c *gin.Context) {
+		props := map[string]interface{}{
+			"name": "World",
+		}
+
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(renderer.RenderPage("index", props)))
+	})
+
+	// serve the page chunks
+	r.Static(config.GetConfig().PublicPath, config.StaticPath)
+
+	log.Fatal(r.Run("{{.Address}}"))
+}
+`
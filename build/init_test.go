@@ -0,0 +1,154 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/shynxe/greact/config"
+)
+
+func Test_scaffoldGitignore(t *testing.T) {
+	defer func() { config.Profile = "" }()
+
+	tests := []struct {
+		name     string
+		existing string
+		want     string
+	}{
+		{
+			name: "new .gitignore",
+			want: "# greact\n/client/node_modules/\n/client/build/\n/client/static/\n/client/server-webpack.config.js\n/client/webpack.config.js\n",
+		},
+		{
+			name:     "existing entries are kept once",
+			existing: "bin/\n/client/node_modules/\n/client/build/",
+			want:     "bin/\n/client/node_modules/\n/client/build/\n# greact\n/client/static/\n/client/server-webpack.config.js\n/client/webpack.config.js\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configFile := filepath.Join(dir, "greact.env")
+			os.WriteFile(configFile, []byte("CLIENTPATH=./client\nBUILDFOLDER=build\nSTATICFOLDER=static\n"), 0644)
+			if tt.existing != "" {
+				os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(tt.existing), 0644)
+			}
+			if err := config.LoadConfig(configFile); err != nil {
+				t.Fatal(err)
+			}
+
+			// a second run adds nothing
+			for i := 0; i < 2; i++ {
+				if err := scaffoldGitignore(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+			if string(got) != tt.want {
+				t.Errorf("scaffoldGitignore() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_scaffoldServer builds the scaffolded servers against this module,
+// offline: the dependencies come from the module cache
+func Test_scaffoldServer(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found")
+	}
+	defer func() { config.Profile = "" }()
+
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modCache, err := exec.Command("go", "env", "GOMODCACHE").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	downloads := filepath.Join(strings.TrimSpace(string(modCache)), "cache", "download")
+
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "file://"+filepath.ToSlash(downloads))
+	t.Setenv("GOSUMDB", "off")
+
+	for _, router := range []string{RouterNetHTTP, RouterGin} {
+		t.Run(router, func(t *testing.T) {
+			dir := t.TempDir()
+			configFile := filepath.Join(dir, "greact.env")
+			os.WriteFile(configFile, []byte("CLIENTPATH=./client\nAPPURL=http://localhost:8081\n"), 0644)
+			goMod := "module example.com/app\n\ngo 1.18\n\nrequire github.com/shynxe/greact v0.0.0\n\nreplace github.com/shynxe/greact => " + root + "\n"
+			os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644)
+
+			// the module cache can't resolve the latest gin, so require a cached one
+			if router == RouterGin {
+				if testing.Short() {
+					t.Skip("building gin is slow")
+				}
+
+				zips, _ := filepath.Glob(filepath.Join(downloads, "github.com", "gin-gonic", "gin", "@v", "*.zip"))
+				if len(zips) == 0 {
+					t.Skip("gin isn't in the module cache")
+				}
+				// the newest release whose dependencies are cached too
+				sort.Slice(zips, func(i, j int) bool { return releaseNumber(zips[i]) > releaseNumber(zips[j]) })
+				fetched := false
+				for _, zip := range zips {
+					cmd := exec.Command("go", "get", "github.com/gin-gonic/gin@"+strings.TrimSuffix(filepath.Base(zip), ".zip"))
+					cmd.Dir = dir
+					if cmd.Run() == nil {
+						fetched = true
+						break
+					}
+				}
+				if !fetched {
+					t.Skip("no gin release can be fetched offline")
+				}
+			}
+
+			if err := config.LoadConfig(configFile); err != nil {
+				t.Fatal(err)
+			}
+			if err := scaffoldServer(router, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			main, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+			if !strings.Contains(string(main), `"localhost:8081"`) {
+				t.Errorf("main.go doesn't listen on the app URL:\n%s", main)
+			}
+
+			cmd := exec.Command("go", "build", "-o", filepath.Join(dir, "app"), ".")
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("go build: %v\n%s", err, out)
+			}
+
+			// an existing main.go is left alone
+			os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644)
+			if err := scaffoldServer(router, ""); err != nil {
+				t.Fatal(err)
+			}
+			if main, _ := os.ReadFile(filepath.Join(dir, "main.go")); string(main) != "package main\n" {
+				t.Errorf("scaffoldServer() overwrote main.go")
+			}
+		})
+	}
+}
+
+// releaseNumber orders the vX.Y.Z release of a module cache file, e.g. v1.12.0.zip
+func releaseNumber(path string) int {
+	var major, minor, patch int
+	fmt.Sscanf(filepath.Base(path), "v%d.%d.%d", &major, &minor, &patch)
+
+	return major*1000000 + minor*1000 + patch
+}
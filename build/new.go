@@ -0,0 +1,239 @@
+package build
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/shynxe/greact/config"
+)
+
+const (
+	// templatesFolder holds the project templates overriding the default ones, in the client
+	templatesFolder = "greact-templates"
+	// componentsFolder holds the components, in the client
+	componentsFolder = "components"
+)
+
+var (
+	// a page name is also the global the hydration script reads it from
+	pageNamePattern      = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	componentNamePattern = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	// reservedPageNames are globals of the client bundles, and the common
+	// window properties a page would overwrite: every page is assigned to
+	// window[<page>], so e.g. a page named location navigates away
+	reservedPageNames = map[string]bool{
+		"hydrate": true, "render": true,
+		"window": true, "self": true, "top": true, "parent": true, "frames": true, "opener": true,
+		"document": true, "location": true, "history": true, "navigator": true, "name": true,
+		"status": true, "closed": true, "length": true, "origin": true, "event": true,
+		"screen": true, "performance": true, "localStorage": true, "sessionStorage": true,
+		"console": true, "alert": true, "fetch": true, "close": true, "open": true, "print": true,
+		"globalThis": true, "undefined": true, "NaN": true, "Infinity": true,
+	}
+)
+
+// scaffold is a file created by the new command
+type scaffold struct {
+	// Name is the page or component name
+	Name string
+	// Component is the name of the React component, capitalized
+	Component string
+}
+
+// newTemplates are the default templates, by the file name overriding them
+var newTemplates = map[string]string{
+	"page.js":      pageTemplate,
+	"component.js": componentTemplate,
+	"props.json":   propsTemplate,
+}
+
+// New is the main function of the new command:
+// greact new page <name> and greact new component <Name>
+func New(args []string) {
+	err := newScaffold(args)
+	if err != nil {
+		fmt.Println("[greact] error:", err)
+		os.Exit(1)
+	}
+}
+
+func newScaffold(args []string) error {
+	if len(args) < 2 || (args[0] != "page" && args[0] != "component") {
+		return errors.New("usage: greact new page <name> [options] | greact new component <Name> [options]")
+	}
+	kind, name := args[0], args[1]
+
+	var props, force bool
+	flagSet := flag.NewFlagSet("new", flag.ExitOnError)
+	flagSet.StringVar(&configPath, "c", "", "path to config file")
+	flagSet.StringVar(&configPath, "config", "", "path to config file")
+	flagSet.BoolVar(&props, "props", false, "also create a props fixture for the page")
+	flagSet.BoolVar(&force, "force", false, "overwrite existing files")
+	flagSet.Parse(args[2:])
+
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	if !clientExists() {
+		return errors.New("the client doesn't exist, run greact build to create it")
+	}
+
+	if isTypeScriptClient() {
+		return errors.New("TypeScript pages and components aren't supported yet, the bundlers only build .js pages")
+	}
+
+	var files map[string]string
+	var err error
+	if kind == "page" {
+		files, err = newPage(name, props)
+	} else {
+		files, err = newComponent(name)
+	}
+	if err != nil {
+		return err
+	}
+
+	// check every file first, so nothing is created when one exists
+	if !force {
+		for path := range files {
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("%s already exists, use -force to overwrite it", path)
+			}
+		}
+	}
+
+	for path, contents := range files {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			return err
+		}
+		fmt.Println("created", path)
+	}
+
+	return nil
+}
+
+// newPage returns the files of a page, by path. The pages are the .js files
+// at the top of the source folder, so a route can't be nested or dynamic.
+func newPage(name string, props bool) (map[string]string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid page %s: nested pages aren't supported, the pages are the files at the top of %s", name, config.SourcePath)
+	}
+	if strings.ContainsAny(name, "[]:") {
+		return nil, fmt.Errorf("invalid page %s: dynamic segments aren't supported, route the requests to a page in the Go server instead", name)
+	}
+	name = strings.TrimSuffix(name, ".js")
+	if !pageNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid page %s: the name must be a valid JavaScript identifier", name)
+	}
+	if reservedPageNames[name] {
+		return nil, fmt.Errorf("invalid page %s: the name is a global of the page or of the hydration script", name)
+	}
+
+	data := scaffold{Name: name, Component: strings.ToUpper(name[:1]) + name[1:]}
+
+	files := map[string]string{}
+	page, err := renderScaffold("page.js", data)
+	if err != nil {
+		return nil, err
+	}
+	files[filepath.Join(config.SourcePath, name+".js")] = page
+
+	// the fixture is next to the page, only the .js files are pages
+	if props {
+		fixture, err := renderScaffold("props.json", data)
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Join(config.SourcePath, name+".props.json")] = fixture
+	}
+
+	return files, nil
+}
+
+// newComponent returns the files of a component, by path
+func newComponent(name string) (map[string]string, error) {
+	if !componentNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid component %s: the name must be in PascalCase, e.g. UserCard", name)
+	}
+
+	data := scaffold{Name: name, Component: name}
+
+	component, err := renderScaffold("component.js", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		filepath.Join(config.GetConfig().ClientPath, componentsFolder, name+".js"): component,
+	}, nil
+}
+
+// renderScaffold renders the template of the project templates folder, or
+// the default one
+func renderScaffold(name string, data scaffold) (string, error) {
+	text := newTemplates[name]
+
+	path := filepath.Join(config.GetConfig().ClientPath, templatesFolder, name)
+	if override, err := os.ReadFile(path); err == nil {
+		text = string(override)
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %s: %w", path, err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("invalid template %s: %w", path, err)
+	}
+
+	return buf.String(), nil
+}
+
+// isTypeScriptClient reports whether the client is set up for TypeScript
+func isTypeScriptClient() bool {
+	_, err := os.Stat(filepath.Join(config.GetConfig().ClientPath, "tsconfig.json"))
+	return err == nil
+}
+
+const pageTemplate = `import React from 'react';
+
+const {{.Component}} = (props) => {
+    return (
+        <div>
+            <h1>{{.Name}}</h1>
+        </div>
+    );
+}
+
+export default {{.Component}};
+`
+
+const componentTemplate = `import React from 'react';
+
+const {{.Component}} = ({children}) => {
+    return (
+        <div>
+            {children}
+        </div>
+    );
+}
+
+export default {{.Component}};
+`
+
+const propsTemplate = `{}
+`
@@ -0,0 +1,85 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shynxe/greact/config"
+)
+
+func Test_newScaffold(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "greact.env")
+	err := os.WriteFile(configFile, []byte("CLIENTPATH=./client\nSOURCEFOLDER=pages\nBUILDFOLDER=build\nSTATICFOLDER=static\nPUBLICPATH=/public/\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(dir, "client", "pages"), os.ModePerm)
+
+	// the project template of the components overrides the default one
+	os.MkdirAll(filepath.Join(dir, "client", templatesFolder), os.ModePerm)
+	os.WriteFile(filepath.Join(dir, "client", templatesFolder, "component.js"), []byte("// {{.Component}}\n"), 0644)
+
+	defer func() { configPath, config.Profile = "", "" }()
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "page with props",
+			args: []string{"page", "about", "-props", "-c", configFile},
+			want: map[string]string{
+				"client/pages/about.js":         "const About = (props)",
+				"client/pages/about.props.json": "{}",
+			},
+		},
+		{
+			name: "component from the project template",
+			args: []string{"component", "UserCard", "-c", configFile},
+			want: map[string]string{"client/components/UserCard.js": "// UserCard"},
+		},
+		{name: "existing page", args: []string{"page", "about", "-c", configFile}, wantErr: "already exists"},
+		{name: "nested page", args: []string{"page", "blog/post", "-c", configFile}, wantErr: "nested"},
+		{name: "dynamic page", args: []string{"page", "[id]", "-c", configFile}, wantErr: "dynamic"},
+		{name: "invalid page", args: []string{"page", "about-us", "-c", configFile}, wantErr: "identifier"},
+		{name: "reserved page", args: []string{"page", "hydrate", "-c", configFile}, wantErr: "hydration"},
+		{name: "window global page", args: []string{"page", "location", "-c", configFile}, wantErr: "global"},
+		{name: "invalid component", args: []string{"component", "userCard", "-c", configFile}, wantErr: "PascalCase"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newScaffold(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("newScaffold() error = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for file, want := range tt.want {
+				data, err := os.ReadFile(filepath.Join(dir, file))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(data), want) {
+					t.Errorf("%s = %q, want it to contain %q", file, data, want)
+				}
+			}
+		})
+	}
+
+	// TypeScript clients aren't built
+	os.WriteFile(filepath.Join(dir, "client", "tsconfig.json"), []byte("{}"), 0644)
+	if err := newScaffold([]string{"page", "contact", "-c", configFile}); err == nil {
+		t.Error("newScaffold() in a TypeScript client error = nil, want an error")
+	}
+}
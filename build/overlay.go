@@ -0,0 +1,181 @@
+package build
+
+import (
+	"errors"
+	"sync"
+)
+
+// devMessage is sent to the dev client over the websocket
+type devMessage struct {
+	// Type is "refresh", "error" or "ok"
+	Type   string         `json:"type"`
+	Errors []overlayError `json:"errors,omitempty"`
+}
+
+// overlayError is a failed build as shown in the browser overlay
+type overlayError struct {
+	Target      string       `json:"target,omitempty"`
+	Message     string       `json:"message,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+var (
+	// lastBuildErr is the error of the last dev build, sent to clients as they connect
+	lastBuildErr   error
+	lastBuildErrMu sync.Mutex
+)
+
+// reportBuildResult pushes the result of a dev build to the browser, which
+// shows the diagnostics in an overlay, or clears it on success
+func reportBuildResult(err error) {
+	lastBuildErrMu.Lock()
+	lastBuildErr = err
+	lastBuildErrMu.Unlock()
+
+	devHub.Broadcast(buildResultMessage(err))
+}
+
+// reportTargetResult replaces the result of one target in the last dev build
+// result, and pushes it to the browser
+func reportTargetResult(target string, targetErr error) {
+	lastBuildErrMu.Lock()
+	var errs BuildErrors
+	for _, err := range splitBuildErrors(lastBuildErr) {
+		var buildErr *BuildError
+		if errors.As(err, &buildErr) && buildErr.Target == target {
+			continue
+		}
+		errs = append(errs, err)
+	}
+	if targetErr != nil {
+		errs = append(errs, targetErr)
+	}
+	lastBuildErrMu.Unlock()
+
+	if len(errs) == 0 {
+		reportBuildResult(nil)
+		return
+	}
+
+	if targetErr != nil {
+		printBuildError(targetErr)
+	}
+	reportBuildResult(errs)
+}
+
+func currentBuildResultMessage() devMessage {
+	lastBuildErrMu.Lock()
+	defer lastBuildErrMu.Unlock()
+
+	return buildResultMessage(lastBuildErr)
+}
+
+// splitBuildErrors returns the errors of the targets of a build error
+func splitBuildErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	var buildErrs BuildErrors
+	if errors.As(err, &buildErrs) {
+		return buildErrs
+	}
+
+	return []error{err}
+}
+
+func buildResultMessage(err error) devMessage {
+	if err == nil {
+		return devMessage{Type: "ok"}
+	}
+
+	message := devMessage{Type: "error"}
+	for _, err := range splitBuildErrors(err) {
+		var buildErr *BuildError
+		if !errors.As(err, &buildErr) {
+			message.Errors = append(message.Errors, overlayError{Message: err.Error()})
+			continue
+		}
+
+		overlayErr := overlayError{Target: buildErr.Target, Diagnostics: buildErr.Diagnostics}
+		if len(buildErr.Diagnostics) == 0 {
+			overlayErr.Message = buildErr.Output
+			if overlayErr.Message == "" {
+				overlayErr.Message = buildErr.Err.Error()
+			}
+		}
+		message.Errors = append(message.Errors, overlayErr)
+	}
+
+	return message
+}
+
+const overlayScript = `<script type="text/javascript">
+(function () {
+  const id = '__greact-overlay';
+
+  window.__greactOverlay = {
+    show: function (errors) {
+      if (!document.body) {
+        document.addEventListener('DOMContentLoaded', this.show.bind(this, errors));
+        return;
+      }
+
+      this.hide();
+
+      const overlay = document.createElement('div');
+      overlay.id = id;
+      overlay.style.cssText = 'position:fixed;inset:0;z-index:2147483647;overflow:auto;padding:32px;' +
+        'background:rgba(24,24,27,0.95);color:#f4f4f5;font:14px/1.5 ui-monospace,Menlo,Consolas,monospace;';
+
+      const title = document.createElement('h2');
+      title.textContent = 'Failed to compile';
+      title.style.cssText = 'margin:0 0 24px;color:#f87171;font-size:20px;';
+      overlay.appendChild(title);
+
+      errors.forEach(function (error) {
+        const section = document.createElement('div');
+        section.style.cssText = 'margin-bottom:24px;';
+
+        if (error.target) {
+          const target = document.createElement('div');
+          target.textContent = error.target + ' bundle';
+          target.style.cssText = 'color:#a1a1aa;text-transform:uppercase;font-size:12px;margin-bottom:8px;';
+          section.appendChild(target);
+        }
+
+        const items = (error.diagnostics || []).map(function (d) {
+          return { location: d.file ? d.file + (d.line ? ':' + d.line + ':' + d.column : '') : '', message: d.message };
+        });
+        if (error.message) {
+          items.push({ location: '', message: error.message });
+        }
+
+        items.forEach(function (item) {
+          if (item.location) {
+            const location = document.createElement('div');
+            location.textContent = item.location;
+            location.style.cssText = 'color:#93c5fd;';
+            section.appendChild(location);
+          }
+
+          const message = document.createElement('pre');
+          message.textContent = item.message;
+          message.style.cssText = 'margin:4px 0 16px;white-space:pre-wrap;';
+          section.appendChild(message);
+        });
+
+        overlay.appendChild(section);
+      });
+
+      document.body.appendChild(overlay);
+    },
+    hide: function () {
+      const overlay = document.getElementById(id);
+      if (overlay) {
+        overlay.remove();
+      }
+    },
+  };
+})();
+</script>`
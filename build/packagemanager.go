@@ -0,0 +1,150 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/shynxe/greact/config"
+)
+
+const (
+	PackageManagerNPM  = "npm"
+	PackageManagerYarn = "yarn"
+	PackageManagerPNPM = "pnpm"
+	PackageManagerBun  = "bun"
+)
+
+// lockfiles maps every lockfile to the package manager that writes it, in detection order
+var lockfiles = []struct {
+	name           string
+	packageManager string
+}{
+	{"bun.lockb", PackageManagerBun},
+	{"bun.lock", PackageManagerBun},
+	{"pnpm-lock.yaml", PackageManagerPNPM},
+	{"yarn.lock", PackageManagerYarn},
+	{"package-lock.json", PackageManagerNPM},
+}
+
+// dependencies of the client, with their pinned version ranges
+var dependencies = map[string]string{
+	"react":     "^18.2.0",
+	"react-dom": "^18.2.0",
+}
+
+// devDependencies of the client, only needed by the webpack bundler
+var devDependencies = map[string]string{
+	"@babel/cli":                               "^7.20.7",
+	"@babel/core":                              "^7.20.12",
+	"@babel/plugin-transform-react-jsx":        "^7.20.13",
+	"@babel/plugin-transform-react-jsx-source": "^7.19.6",
+	"@babel/preset-react":                      "^7.18.6",
+	"@pmmmwh/react-refresh-webpack-plugin":     "^0.5.10",
+	"babel-loader":                             "^9.1.2",
+	"react-refresh":                            "^0.14.0",
+	"webpack":                                  "^5.75.0",
+	"webpack-cli":                              "^5.0.1",
+	"webpack-dev-server":                       "^4.11.1",
+}
+
+type packageJSON struct {
+	Name            string            `json:"name"`
+	Version         string            `json:"version"`
+	Private         bool              `json:"private"`
+	Author          string            `json:"author"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies,omitempty"`
+}
+
+// detectPackageManager returns the package manager set in the config, the
+// "packageManager" field of package.json, or the one owning a lockfile in one
+// of dirs, in that order. It falls back to npm.
+func detectPackageManager(dirs ...string) string {
+	if packageManager := config.GetConfig().PackageManager; packageManager != "" {
+		return packageManager
+	}
+
+	for _, dir := range dirs {
+		if packageManager := packageJSONPackageManager(dir); packageManager != "" {
+			return packageManager
+		}
+
+		for _, lockfile := range lockfiles {
+			if _, err := os.Stat(filepath.Join(dir, lockfile.name)); err == nil {
+				return lockfile.packageManager
+			}
+		}
+	}
+
+	return PackageManagerNPM
+}
+
+// packageJSONPackageManager reads the corepack "packageManager" field, e.g. "pnpm@7.25.0"
+func packageJSONPackageManager(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return ""
+	}
+
+	var pkg struct {
+		PackageManager string `json:"packageManager"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return ""
+	}
+
+	name, _, _ := strings.Cut(pkg.PackageManager, "@")
+	return name
+}
+
+// writePackageJSON writes the package.json of the client in clientPath with all of its dependencies
+func writePackageJSON(clientPath string) error {
+	pkg := packageJSON{
+		Name:         "greact",
+		Version:      "1.0.0",
+		Private:      true,
+		Dependencies: dependencies,
+	}
+
+	// esbuild bundles in-process and only needs the runtime dependencies
+	if config.GetConfig().Bundler != BundlerESBuild {
+		pkg.DevDependencies = devDependencies
+	}
+
+	data, err := json.MarshalIndent(pkg, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(clientPath, "package.json"), append(data, '\n'), 0644)
+}
+
+// installDependencies installs the dependencies of the client in clientPath
+func installDependencies(clientPath string) error {
+	packageManager := detectPackageManager(clientPath, ".")
+
+	if _, err := exec.LookPath(packageManager); err != nil {
+		return fmt.Errorf("%s is not installed: %w", packageManager, err)
+	}
+
+	fmt.Printf("installing dependencies with %s...\n", packageManager)
+
+	// stream the output, but keep stderr to report it on failure
+	var stderr bytes.Buffer
+	cmd := exec.Command(packageManager, "install")
+	cmd.Dir = clientPath
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s install failed: %w\n%s", packageManager, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
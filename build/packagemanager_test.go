@@ -0,0 +1,88 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func Test_detectPackageManager(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "Test no lockfile",
+			files: map[string]string{},
+			want:  "npm",
+		},
+		{
+			name:  "Test yarn lockfile",
+			files: map[string]string{"yarn.lock": ""},
+			want:  "yarn",
+		},
+		{
+			name:  "Test pnpm lockfile",
+			files: map[string]string{"pnpm-lock.yaml": ""},
+			want:  "pnpm",
+		},
+		{
+			name:  "Test bun lockfile",
+			files: map[string]string{"bun.lockb": ""},
+			want:  "bun",
+		},
+		{
+			name: "Test packageManager field wins over lockfile",
+			files: map[string]string{
+				"package.json":      `{"packageManager": "pnpm@7.25.0"}`,
+				"package-lock.json": "",
+			},
+			want: "pnpm",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, contents := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := detectPackageManager(dir); got != tt.want {
+				t.Errorf("detectPackageManager() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_devDependencies checks that the packages the webpack configs load are
+// installed, without relying on the hoisting of npm and yarn
+func Test_devDependencies(t *testing.T) {
+	packageNames := regexp.MustCompile(`['"](@babel/[a-z0-9-]+|[a-z0-9-]+-loader)['"]|require(?:\.resolve)?\('([^']+)'\)`)
+	builtins := map[string]bool{"path": true, "crypto": true, "fs": true}
+
+	for name, template := range map[string]string{"webpack.config.js": webpackConfigTemplate, "server-webpack.config.js": serverWebpackConfig} {
+		for _, match := range packageNames.FindAllStringSubmatch(template, -1) {
+			pkg := match[1]
+			if pkg == "" {
+				// the package of a required path, e.g. react-refresh/babel
+				segments := strings.SplitN(match[2], "/", 3)
+				pkg = segments[0]
+				if strings.HasPrefix(pkg, "@") && len(segments) > 1 {
+					pkg += "/" + segments[1]
+				}
+			}
+
+			if builtins[pkg] {
+				continue
+			}
+			if _, ok := devDependencies[pkg]; !ok {
+				t.Errorf("%s loads %s, which isn't in devDependencies", name, pkg)
+			}
+		}
+	}
+}
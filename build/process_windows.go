@@ -0,0 +1,20 @@
+//go:build windows
+
+package build
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// forwardedSignals are relayed by greact run to the app
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+
+// setProcessGroup is a no-op on windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
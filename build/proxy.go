@@ -0,0 +1,226 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shynxe/greact/config"
+	"github.com/shynxe/greact/renderer"
+)
+
+const (
+	DefaultAppURL       = config.DefaultAppURL
+	DefaultDevProxyPort = config.DefaultDevProxyPort
+	DefaultHealthPath   = config.DefaultDevHealthPath
+
+	// proxyWaitTimeout is how long a request waits for the app to come back up
+	proxyWaitTimeout = 30 * time.Second
+	// healthTimeout is how long a restarted app gets to pass the health check
+	healthTimeout = 30 * time.Second
+	// healthInterval is the delay between two health checks
+	healthInterval = 100 * time.Millisecond
+)
+
+// devProxy sits in front of the user's app in dev mode. It injects the dev
+// client into the HTML responses, and holds the requests back while the app
+// restarts.
+type devProxy struct {
+	target     *url.URL
+	healthPath string
+	proxy      *httputil.ReverseProxy
+
+	mu sync.Mutex
+	// ready is closed while the app is up, or when it couldn't be started
+	ready chan struct{}
+	// err is why the app couldn't be started, answered to the requests until
+	// the next restart
+	err error
+}
+
+// proxySettings returns the app URL, the proxy port and the health check path
+func proxySettings() (appURL string, port int, healthPath string) {
+	userConfig := config.GetConfig()
+
+	return userConfig.AppURL, userConfig.DevProxyPort, userConfig.DevHealthPath
+}
+
+func newDevProxy(target *url.URL, healthPath string) *devProxy {
+	p := &devProxy{
+		target:     target,
+		healthPath: healthPath,
+		ready:      make(chan struct{}),
+	}
+
+	p.proxy = httputil.NewSingleHostReverseProxy(target)
+	director := p.proxy.Director
+	p.proxy.Director = func(r *http.Request) {
+		director(r)
+		// the HTML must come uncompressed to inject the dev client
+		r.Header.Del("Accept-Encoding")
+	}
+	p.proxy.ModifyResponse = injectDevClient
+	p.proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		http.Error(w, fmt.Sprintf("[greact] app at %s is not responding: %v", target, err), http.StatusBadGateway)
+	}
+
+	return p
+}
+
+func (p *devProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	ready := p.ready
+	p.mu.Unlock()
+
+	select {
+	case <-ready:
+	case <-r.Context().Done():
+		return
+	case <-time.After(proxyWaitTimeout):
+		http.Error(w, "[greact] app is still restarting", http.StatusServiceUnavailable)
+		return
+	}
+
+	p.mu.Lock()
+	err := p.err
+	p.mu.Unlock()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("[greact] app failed to start: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	p.proxy.ServeHTTP(w, r)
+}
+
+// restarting holds back the requests until the next successful health check
+func (p *devProxy) restarting() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.ready:
+		p.ready = make(chan struct{})
+	default:
+	}
+	p.err = nil
+}
+
+// failed releases the held back requests with err, until the next restart
+func (p *devProxy) failed(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.ready:
+	default:
+		close(p.ready)
+	}
+	p.err = err
+}
+
+// waitHealthy polls the health check path until the app answers without a
+// server error, then releases the held back requests
+func (p *devProxy) waitHealthy() error {
+	healthURL := *p.target
+	healthURL.Path = p.healthPath
+
+	client := &http.Client{Timeout: time.Second}
+	deadline := time.Now().Add(healthTimeout)
+
+	for time.Now().Before(deadline) {
+		resp, err := client.Get(healthURL.String())
+		if err == nil {
+			resp.Body.Close()
+
+			if resp.StatusCode < http.StatusInternalServerError {
+				p.mu.Lock()
+				select {
+				case <-p.ready:
+				default:
+					close(p.ready)
+				}
+				p.mu.Unlock()
+
+				return nil
+			}
+		}
+
+		time.Sleep(healthInterval)
+	}
+
+	return fmt.Errorf("app at %s didn't pass the health check within %s", healthURL.String(), healthTimeout)
+}
+
+// listenDevProxy listens on the configured proxy port, or the next free one
+func listenDevProxy() (net.Listener, error) {
+	host, _, _ := devSettings()
+	_, port, _ := proxySettings()
+
+	listener, err := listenFreePort(host, port, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if actual := listener.Addr().(*net.TCPAddr).Port; actual != port {
+		fmt.Printf("port %d is busy, dev proxy listening on port %d\n", port, actual)
+	}
+
+	return listener, nil
+}
+
+func serveDevProxy(listener net.Listener, p *devProxy) {
+	fmt.Printf("[greact] dev server ready at http://localhost:%d\n", listener.Addr().(*net.TCPAddr).Port)
+
+	if err := http.Serve(listener, p); err != nil {
+		log.Println("Error serving dev proxy:", err)
+	}
+}
+
+// injectDevClient adds the error overlay and the refresh script to HTML responses
+func injectDevClient(resp *http.Response) error {
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	// the template can place the dev client, otherwise it goes at the end of the head
+	devClient := []byte(overlayScript + refreshScript())
+	if bytes.Contains(body, []byte(renderer.DevClientMarker)) {
+		body = bytes.Replace(body, []byte(renderer.DevClientMarker), devClient, 1)
+	} else {
+		body = injectHTML(body, string(devClient))
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return nil
+}
+
+// injectHTML inserts the snippet before </head>, or before </body>, or at the end of the document
+func injectHTML(html []byte, snippet string) []byte {
+	lower := bytes.ToLower(html)
+
+	for _, tag := range []string{"</head>", "</body>"} {
+		if i := bytes.Index(lower, []byte(tag)); i >= 0 {
+			return append(html[:i:i], append([]byte(snippet), html[i:]...)...)
+		}
+	}
+
+	return append(html, snippet...)
+}
@@ -0,0 +1,81 @@
+package build
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_injectHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "before head",
+			html: "<html><head><title>x</title></head><body></body></html>",
+			want: "<html><head><title>x</title><script></script></head><body></body></html>",
+		},
+		{
+			name: "uppercase tags",
+			html: "<HTML><HEAD></HEAD></HTML>",
+			want: "<HTML><HEAD><script></script></HEAD></HTML>",
+		},
+		{
+			name: "before body without head",
+			html: "<body><p>x</p></body>",
+			want: "<body><p>x</p><script></script></body>",
+		},
+		{
+			name: "fragment",
+			html: "<p>x</p>",
+			want: "<p>x</p><script></script>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(injectHTML([]byte(tt.html), "<script></script>")); got != tt.want {
+				t.Errorf("injectHTML() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_devProxy_failed(t *testing.T) {
+	target, _ := url.Parse("http://127.0.0.1:1")
+	p := newDevProxy(target, "/")
+
+	// the request is held back until the app starts, or fails to
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		done <- w
+	}()
+
+	p.failed(errors.New("exec format error"))
+
+	select {
+	case w := <-done:
+		if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "exec format error") {
+			t.Errorf("ServeHTTP() after failed = %d %q, want %d with the error", w.Code, w.Body.String(), http.StatusBadGateway)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeHTTP() still waiting after failed")
+	}
+
+	// the next restart holds the requests back again
+	p.restarting()
+	p.mu.Lock()
+	err := p.err
+	p.mu.Unlock()
+	if err != nil {
+		t.Errorf("restarting() kept the error %v", err)
+	}
+}
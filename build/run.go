@@ -1,60 +1,231 @@
 package build
 
 import (
+	"errors"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
 	"syscall"
+	"time"
+
+	"github.com/shynxe/greact/config"
+	"github.com/subosito/gotenv"
+)
+
+const (
+	// restartBackoffMin and restartBackoffMax bound the delay before restarting a crashed app
+	restartBackoffMin = 500 * time.Millisecond
+	restartBackoffMax = 30 * time.Second
+	// restartBackoffReset is how long the app must run to reset the backoff
+	restartBackoffReset = 10 * time.Second
+)
+
+var (
+	envFile   string
+	envVars   stringList
+	noRestart bool
+	// appArgs are the arguments after --, passed to the app
+	appArgs []string
 )
 
-// Run is the main function of the run command
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Run is the main function of the run command. It builds the pages and the
+// Go app, then supervises the app until greact is stopped, and exits with the
+// app's exit code.
 func Run(args []string) {
-	Build(args)
-	run()
+	if err := buildCommand("run", args); err != nil {
+		os.Exit(1)
+	}
+
+	if code := run(); code != 0 {
+		os.Exit(code)
+	}
 }
 
-// run runs the built application
-func run() {
-	cmd := exec.Command("go", "build", "-o", "app")
-	output, err := cmd.Output()
+// run builds the Go app outside of the project and supervises it
+func run() int {
+	env, err := appEnv(envFile, envVars)
+	if err != nil {
+		fmt.Println("[greact] error:", err)
+		return 1
+	}
+
+	target, err := goTargetSettings()
 	if err != nil {
-		printError(err)
+		fmt.Println("[greact] error:", err)
+		return 1
 	}
-	fmt.Println(string(output))
 
-	cmd = exec.Command("./app")
-	stdout, err := cmd.StdoutPipe()
+	// the binary lives outside of the project, removed however the app exits
+	binDir, cleanup, err := target.binDir("greact-run-")
 	if err != nil {
-		printError(err)
+		fmt.Println("[greact] error:", err)
+		return 1
+	}
+	defer cleanup()
+
+	binary := filepath.Join(binDir, binaryName("app"))
+	if err := buildApp(target, binary); err != nil {
+		fmt.Println("[greact] error building app:", err)
+		return 1
 	}
-	if err := cmd.Start(); err != nil {
-		printError(err)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	return supervise(binary, target.Dir, appArgs, env, !noRestart, signals)
+}
+
+// supervise runs the binary until it exits cleanly or a terminating signal is
+// received, forwarding the signals to it and restarting it with an
+// exponential backoff when it crashes
+func supervise(binary string, dir string, args []string, env []string, restart bool, signals <-chan os.Signal) int {
+	failures := 0
+
+	for {
+		started := time.Now()
+		app, err := startApp(binary, dir, args, env, true)
+		if err != nil {
+			fmt.Println("[greact] error starting app:", err)
+			return 1
+		}
+
+		if sig := waitApp(app, signals); sig != nil {
+			fmt.Printf("[greact] hope you enjoyed the app! (received signal: %v)\n", sig)
+			return exitCode(app)
+		}
+
+		code := exitCode(app)
+		if code == 0 || !restart {
+			return code
+		}
+
+		if time.Since(started) > restartBackoffReset {
+			failures = 0
+		}
+		delay := restartDelay(failures)
+		failures++
+
+		fmt.Printf("[greact] app exited with code %d, restarting in %s\n", code, delay)
+
+		select {
+		case sig := <-signals:
+			fmt.Printf("[greact] hope you enjoyed the app! (received signal: %v)\n", sig)
+			return code
+		case <-time.After(delay):
+		}
 	}
+}
 
-	// Create a channel to receive signals and register it to receive SIGKILL signals
-	sigchan := make(chan os.Signal, 1)
-	signal.Notify(sigchan, syscall.SIGINT)
+// waitApp forwards the signals to the app until it exits, and returns the
+// terminating signal that stopped it, if any
+func waitApp(app *appProcess, signals <-chan os.Signal) os.Signal {
+	for {
+		select {
+		case <-app.done:
+			return nil
+		case sig := <-signals:
+			if terminating(sig) {
+				// the terminal sent its Ctrl-C or Ctrl-\ to the app too, it isn't sent twice
+				if app.sharedGroup && sig != syscall.SIGTERM {
+					app.wait()
+				} else {
+					app.interrupt(sig)
+				}
+				return sig
+			}
 
-	// Start a goroutine to listen for SIGKILL signals and terminate the cmd process when one is received
-	go func() {
-		sig := <-sigchan
-		if sig == syscall.SIGINT {
-			fmt.Println("[greact] hope you enjoyed the app!")
+			app.cmd.Process.Signal(sig)
 		}
-		cmd.Process.Kill()
-	}()
+	}
+}
+
+// terminating reports whether sig stops greact run, instead of only being relayed
+func terminating(sig os.Signal) bool {
+	return sig == syscall.SIGINT || sig == syscall.SIGTERM || sig == syscall.SIGQUIT
+}
+
+// exitCode returns the exit code of an exited app, 128 plus the signal number
+// if it was killed by a signal, like a shell does
+func exitCode(app *appProcess) int {
+	code := app.cmd.ProcessState.ExitCode()
+	if code >= 0 {
+		return code
+	}
+
+	if status, ok := app.cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return 1
+}
+
+// restartDelay doubles after every consecutive crash, up to restartBackoffMax
+func restartDelay(failures int) time.Duration {
+	delay := restartBackoffMin
+	for i := 0; i < failures && delay < restartBackoffMax; i++ {
+		delay *= 2
+	}
 
-	if _, err := io.Copy(os.Stdout, stdout); err != nil {
-		printError(err)
+	if delay > restartBackoffMax {
+		return restartBackoffMax
 	}
 
-	// Remove the app file when the function returns, even if it is terminated by a SIGKILL signal
-	defer os.Remove("app")
+	return delay
 }
 
-func printError(err error) {
-	fmt.Println("[greact] error:", err)
-	os.Exit(1)
+// appEnv returns the environment of the app: the variables of the env file,
+// overridden by the environment of greact, overridden by the config profile
+// and the -e flags
+func appEnv(envFile string, vars []string) ([]string, error) {
+	var env []string
+
+	if envFile != "" {
+		fileVars, err := gotenv.Read(envFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading env file: %w", err)
+		}
+
+		keys := make([]string, 0, len(fileVars))
+		for key := range fileVars {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			env = append(env, key+"="+fileVars[key])
+		}
+	}
+
+	// exec keeps the last value of a duplicated key
+	env = append(env, os.Environ()...)
+
+	// the app loads the same profile as greact
+	if profile := config.ActiveProfile(); profile != "" {
+		env = append(env, config.ProfileEnv+"="+profile)
+	}
+
+	for _, v := range vars {
+		if !strings.Contains(v, "=") {
+			return nil, errors.New("invalid -e value " + v + ", expected KEY=VALUE")
+		}
+		env = append(env, v)
+	}
+
+	return env, nil
 }
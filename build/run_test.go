@@ -0,0 +1,153 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_restartDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 500 * time.Millisecond},
+		{1, time.Second},
+		{3, 4 * time.Second},
+		{6, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := restartDelay(tt.failures); got != tt.want {
+			t.Errorf("restartDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func Test_appEnv(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(envFile, []byte("GREACT_TEST_FILE=file\nGREACT_TEST_SHADOWED=file\n"), 0644)
+	t.Setenv("GREACT_TEST_SHADOWED", "process")
+
+	env, err := appEnv(envFile, []string{"GREACT_TEST_FLAG=flag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, key := range []string{"GREACT_TEST_FILE", "GREACT_TEST_SHADOWED", "GREACT_TEST_FLAG"} {
+		got[key] = lookupEnv(env, key)
+	}
+
+	want := map[string]string{
+		"GREACT_TEST_FILE":     "file",
+		"GREACT_TEST_SHADOWED": "process",
+		"GREACT_TEST_FLAG":     "flag",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("appEnv() = %v, want %v", got, want)
+	}
+
+	if _, err := appEnv("", []string{"INVALID"}); err == nil {
+		t.Error("appEnv() error = nil, want an error for a value without =")
+	}
+}
+
+// lookupEnv returns the last value of key, like exec does
+func lookupEnv(env []string, key string) string {
+	value := ""
+	for _, kv := range env {
+		if len(kv) > len(key) && kv[:len(key)+1] == key+"=" {
+			value = kv[len(key)+1:]
+		}
+	}
+	return value
+}
+
+func Test_supervise(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	counter := filepath.Join(t.TempDir(), "runs")
+
+	tests := []struct {
+		name    string
+		script  string
+		restart bool
+		want    int
+	}{
+		{"clean exit", "exit 0", true, 0},
+		{"crash without restart", "exit 3", false, 3},
+		// crashes on the first run, exits cleanly on the second
+		{"restart after crash", "if [ -f " + counter + " ]; then exit 0; fi; touch " + counter + "; exit 2", true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals := make(chan os.Signal)
+			if got := supervise("sh", ".", []string{"-c", tt.script}, nil, tt.restart, signals); got != tt.want {
+				t.Errorf("supervise() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_supervise_signal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	signals := make(chan os.Signal, 1)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		signals <- syscall.SIGTERM
+	}()
+
+	// killed by the relayed signal, like a shell reports it
+	if got, want := supervise("sh", ".", []string{"-c", "exec sleep 5"}, nil, true, signals), 128+int(syscall.SIGTERM); got != want {
+		t.Errorf("supervise() = %v, want %v", got, want)
+	}
+}
+
+func Test_supervise_stdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	w.WriteString("7\n")
+	w.Close()
+
+	// the app reads the input of greact run
+	if got := supervise("sh", ".", []string{"-c", "read code; exit $code"}, nil, false, make(chan os.Signal)); got != 7 {
+		t.Errorf("supervise() = %v, want 7", got)
+	}
+}
+
+func Test_parseFlags_appArgs(t *testing.T) {
+	defer func() { devMode, forceBuild, appArgs = false, false, nil }()
+
+	// dev puts -dev before the arguments, so that it isn't passed to the app
+	parseFlags("dev", append([]string{"-dev"}, "-force", "--", "-port", "8081"))
+
+	if !devMode {
+		t.Errorf("parseFlags() devMode = false, want true")
+	}
+	if want := []string{"-port", "8081"}; !reflect.DeepEqual(appArgs, want) {
+		t.Errorf("parseFlags() appArgs = %v, want %v", appArgs, want)
+	}
+}
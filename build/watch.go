@@ -0,0 +1,168 @@
+package build
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/shynxe/greact/config"
+)
+
+// watchTree watches root and all of its subdirectories, including the ones
+// created later, for changes. Paths for which ignored returns true are
+// neither watched nor reported. A root that is missing, e.g. the static folder
+// before the first successful build, or deleted is watched once created.
+func watchTree(root string, ignored func(path string, isDir bool) bool, onChange func(e fsnotify.Event)) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer watcher.Close()
+
+	if ignored == nil {
+		ignored = func(string, bool) bool { return false }
+	}
+
+	root = filepath.Clean(root)
+	parent := filepath.Dir(root)
+
+	// the watches of a deleted directory are gone, so notice root coming back.
+	// It isn't created here: a missing client means it has to be scaffolded.
+	if err := watcher.Add(parent); err != nil {
+		log.Println("error:", err)
+	}
+
+	if err := addTree(watcher, root, ignored); err != nil && !os.IsNotExist(err) {
+		log.Println("error:", err)
+	}
+
+	for {
+		select {
+		case event := <-watcher.Events:
+			// the parent is only watched for root itself
+			if filepath.Dir(event.Name) == parent && event.Name != root {
+				continue
+			}
+
+			info, err := os.Stat(event.Name)
+			isDir := err == nil && info.IsDir()
+
+			if event.Name != root && ignored(event.Name, isDir) {
+				continue
+			}
+
+			// fsnotify isn't recursive, so watch the new directories too
+			if isDir && event.Op&fsnotify.Create != 0 {
+				if err := addTree(watcher, event.Name, ignored); err != nil {
+					log.Println("error:", err)
+				}
+			}
+
+			onChange(event)
+		case err := <-watcher.Errors:
+			log.Println("error:", err)
+		}
+	}
+}
+
+func addTree(watcher *fsnotify.Watcher, root string, ignored func(path string, isDir bool) bool) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if path != root && ignored(path, true) {
+			return filepath.SkipDir
+		}
+
+		return watcher.Add(path)
+	})
+}
+
+// ignoredClientPath reports whether a change to path doesn't need a rebuild:
+// the dependencies, the build outputs, the generated files and the paths
+// matching the watchIgnore globs (or not matching the watchInclude globs).
+func ignoredClientPath(p string, isDir bool) bool {
+	userConfig := config.GetConfig()
+	clientPath := filepath.Clean(userConfig.ClientPath)
+
+	rel, err := filepath.Rel(clientPath, p)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+
+	switch filepath.Clean(p) {
+	case filepath.Join(clientPath, "node_modules"), filepath.Clean(config.BuildPath), filepath.Clean(config.StaticPath):
+		return true
+	}
+
+	if !isDir && filepath.Dir(filepath.Clean(p)) == clientPath && generatedFiles[filepath.Base(p)] {
+		return true
+	}
+
+	for _, pattern := range userConfig.WatchIgnore {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+
+	// directories are always walked, the includes only filter the files
+	if isDir || len(userConfig.WatchInclude) == 0 {
+		return false
+	}
+
+	for _, pattern := range userConfig.WatchInclude {
+		if matchGlob(pattern, rel) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchGlob matches a slash separated path against a glob pattern, where "**"
+// matches any number of directories. A pattern without a slash matches the
+// base name at any depth, e.g. "*.test.js".
+func matchGlob(pattern string, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// "**" swallows zero or more segments
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
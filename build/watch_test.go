@@ -0,0 +1,76 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func Test_matchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "*.test.js", name: "pages/components/Button.test.js", want: true},
+		{pattern: "*.test.js", name: "pages/components/Button.js", want: false},
+		{pattern: "pages/*.js", name: "pages/index.js", want: true},
+		{pattern: "pages/*.js", name: "pages/components/Button.js", want: false},
+		{pattern: "pages/**/*.js", name: "pages/index.js", want: true},
+		{pattern: "pages/**/*.js", name: "pages/components/forms/Input.js", want: true},
+		{pattern: "**/__snapshots__/**", name: "pages/__snapshots__/index.snap", want: true},
+		{pattern: "**/__snapshots__/**", name: "pages/index.js", want: false},
+		{pattern: "styles/**", name: "styles", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+				t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_watchTree(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "static")
+
+	events := make(chan fsnotify.Event, 100)
+	go watchTree(root, nil, func(e fsnotify.Event) { events <- e })
+
+	// waits for an op on name
+	waitEvent := func(name string, op fsnotify.Op) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case got := <-events:
+				if got.Name == name && got.Op&op != 0 {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("no event for %s", name)
+			}
+		}
+	}
+
+	// let the watcher start
+	time.Sleep(100 * time.Millisecond)
+
+	// root is missing at first, then deleted and created again
+	for i := 0; i < 2; i++ {
+		if err := os.Mkdir(root, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		waitEvent(root, fsnotify.Create)
+
+		file := filepath.Join(root, "index.js")
+		os.WriteFile(file, nil, 0644)
+		waitEvent(file, fsnotify.Create)
+
+		os.RemoveAll(root)
+		waitEvent(root, fsnotify.Remove)
+	}
+}
@@ -1,19 +1,27 @@
 package build
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"text/template"
 
 	"github.com/shynxe/greact/config"
 )
 
 type WebpackConfig struct {
-	EntryPoints        string
-	HtmlWebpackPlugins string
-	BuildFolder        string
-	StaticFolder       string
-	PublicPath         string
+	EntryPoints  string
+	BuildFolder  string
+	StaticFolder string
+	PublicPath   string
+	// HMR builds the client in development mode with React Fast Refresh
+	HMR bool
+	// HMRStatsFile is written in the build folder after every compilation in HMR mode
+	HMRStatsFile string
 }
 
 var getSourceFiles = func() []string {
@@ -52,28 +60,115 @@ func getWebpackConfig(userConfig config.Config) WebpackConfig {
 	jsFiles := getJSSourceFiles()
 
 	entryPoints := ""
-	htmlWebpackPlugins := ""
 	for _, file := range jsFiles {
 		fileNameNoExt := filepath.Base(file)
 		fileNameNoExt = fileNameNoExt[:len(fileNameNoExt)-len(".js")]
 		entryPoints += fileNameNoExt + ": path.join(__dirname, '" + userConfig.SourceFolder + "', '" + file + "'),\n\t\t"
-		htmlWebpackPlugins += "new HtmlWebpackPlugin({\n\t\t\ttemplate: path.join(__dirname, '" + userConfig.BuildFolder + "', '.greact-template.html'),\n\t\t\tfilename: '" + fileNameNoExt + ".html',\n\t\t\tchunks: ['hydrate', '" + fileNameNoExt + "'],\n\t\t\tpublicPath: '" + userConfig.PublicPath + "',\n\t\t}),\n\t\t"
 	}
 
 	return WebpackConfig{
-		EntryPoints:        entryPoints,
-		HtmlWebpackPlugins: htmlWebpackPlugins,
-		BuildFolder:        userConfig.BuildFolder,
-		StaticFolder:       userConfig.StaticFolder,
-		PublicPath:         userConfig.PublicPath,
+		EntryPoints:  entryPoints,
+		BuildFolder:  userConfig.BuildFolder,
+		StaticFolder: userConfig.StaticFolder,
+		PublicPath:   userConfig.PublicPath,
+		HMR:          hmrMode,
+		HMRStatsFile: hmrStatsFileName,
 	}
 }
 
+type webpackBundler struct {
+	// watcher is the webpack --watch process compiling the client in HMR mode
+	watcher *exec.Cmd
+	// watcherDone is closed when the watcher exits
+	watcherDone chan struct{}
+}
+
+func (b *webpackBundler) BuildClient(ctx context.Context) error {
+	if hmrMode {
+		return b.watchClient(ctx)
+	}
+
+	err := createWebpackConfig()
+	if err != nil {
+		return fmt.Errorf("error creating webpack config: %w", err)
+	}
+
+	return runWebpack(ctx, TargetClient, "--mode", "production")
+}
+
+func (b *webpackBundler) BuildServer(ctx context.Context) error {
+	err := createServerWebpackConfig()
+	if err != nil {
+		return fmt.Errorf("error creating server webpack config: %w", err)
+	}
+
+	return runWebpack(ctx, TargetServer, "--mode", "production", "--config", "server-webpack.config.js")
+}
+
+// runWebpack calls webpack in the clientPath directory, capturing its output
+// and reading the errors from the stats it writes next to the bundles. The
+// whole process group is killed when ctx is cancelled.
+func runWebpack(ctx context.Context, target string, args ...string) error {
+	statsPath, err := filepath.Abs(filepath.Join(config.BuildPath, ".greact-"+target+"-stats.json"))
+	if err != nil {
+		return err
+	}
+	os.Remove(statsPath)
+
+	var output bytes.Buffer
+	cmd := exec.Command("npx", append(append([]string{"webpack"}, args...), "--json", statsPath)...)
+	cmd.Dir = config.GetConfig().ClientPath
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	setProcessGroup(cmd)
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-done:
+		}
+	}()
+
+	err = cmd.Wait()
+	close(done)
+	if err == nil {
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	buildErr := &BuildError{Target: target, Output: strings.TrimSpace(output.String()), Err: err}
+
+	// the stats are missing when webpack fails before compiling, e.g. on an invalid config
+	if data, readErr := os.ReadFile(statsPath); readErr == nil {
+		buildErr.Diagnostics, _ = parseWebpackStats(data, config.GetConfig().ClientPath)
+	}
+
+	return buildErr
+}
+
 func createWebpackConfig() error {
+	return writeWebpackConfig("webpack.config.js", webpackConfigTemplate)
+}
+
+func createServerWebpackConfig() error {
+	return writeWebpackConfig("server-webpack.config.js", serverWebpackConfig)
+}
+
+func writeWebpackConfig(name string, configTemplate string) error {
 	userConfig := config.GetConfig()
 
 	// create template
-	tmpl, err := template.New("webpack").Parse(webpackConfigTemplate)
+	tmpl, err := template.New(name).Parse(configTemplate)
 	if err != nil {
 		return err
 	}
@@ -86,8 +181,7 @@ func createWebpackConfig() error {
 		}
 	}
 
-	// create webpack config for client pages
-	f, err := os.Create(userConfig.ClientPath + "/webpack.config.js")
+	f, err := os.Create(filepath.Join(userConfig.ClientPath, name))
 	if err != nil {
 		return err
 	}
@@ -100,39 +194,87 @@ func createWebpackConfig() error {
 		return err
 	}
 
-	// now create server webpack config
-	tmpl, err = template.New("webpack-server").Parse(serverWebpackConfig)
-	if err != nil {
-		return err
-	}
+	return nil
+}
 
-	f, err = os.Create(userConfig.ClientPath + "/server-webpack.config.js")
-	if err != nil {
-		return err
-	}
+const webpackConfigTemplate = `const path = require('path');
+const crypto = require('crypto');
+{{- if .HMR}}
+const fs = require('fs');
+const webpack = require('webpack');
+const ReactRefreshWebpackPlugin = require('@pmmmwh/react-refresh-webpack-plugin');
 
-	err = tmpl.Execute(f, webpackConfig)
-	if err != nil {
-		return err
+// writes the errors of every compilation of webpack --watch, for the greact overlay
+class GreactStatsPlugin {
+	apply(compiler) {
+		compiler.hooks.done.tap('GreactStatsPlugin', (stats) => {
+			const statsPath = path.join(__dirname, "{{.BuildFolder}}", "{{.HMRStatsFile}}");
+			fs.writeFileSync(statsPath + '.tmp', JSON.stringify(stats.toJson({ all: false, errors: true })));
+			fs.renameSync(statsPath + '.tmp', statsPath);
+		});
 	}
-
-	return nil
 }
+{{- end}}
 
-const webpackConfigTemplate = `const path = require('path');
-const HtmlWebpackPlugin = require('html-webpack-plugin');
+// writes manifest.json, mapping every page to its chunks in load order
+class GreactManifestPlugin {
+	apply(compiler) {
+		const { Compilation, sources } = compiler.webpack;
+
+		compiler.hooks.thisCompilation.tap('GreactManifestPlugin', (compilation) => {
+			compilation.hooks.processAssets.tap({
+				name: 'GreactManifestPlugin',
+				stage: Compilation.PROCESS_ASSETS_STAGE_REPORT,
+			}, () => {
+				const asset = (file) => ({
+					file,
+					integrity: 'sha384-' + crypto.createHash('sha384').update(compilation.getAsset(file).source.buffer()).digest('base64'),
+				});
+
+				const shared = compilation.entrypoints.get('hydrate').getFiles();
+				const pages = {};
+				for (const [name, entrypoint] of compilation.entrypoints) {
+					if (name === 'hydrate') {
+						continue;
+					}
+
+					const files = [...new Set([...shared, ...entrypoint.getFiles()])];
+					pages[name] = {
+						js: files.filter((file) => file.endsWith('.js')).map(asset),
+						css: files.filter((file) => file.endsWith('.css')).map(asset),
+					};
+				}
+
+				const manifest = { publicPath: "{{.PublicPath}}", pages };
+				compilation.emitAsset('manifest.json', new sources.RawSource(JSON.stringify(manifest, null, 2)));
+			});
+		});
+	}
+}
 
 module.exports = {
 	entry: {
 		{{.EntryPoints}}
+		{{- if .HMR}}
+		hydrate: [
+			path.join(__dirname, "{{.BuildFolder}}", ".greact-hmr-client.js"),
+			path.join(__dirname, "{{.BuildFolder}}", ".greact-hydrater.js"),
+		],
+		{{- else}}
 		hydrate: path.join(__dirname, "{{.BuildFolder}}", ".greact-hydrater.js"),
+		{{- end}}
 	},
 	output: {
 		path: path.join(__dirname, "{{.StaticFolder}}"),
+		{{- if .HMR}}
+		// hot updates can't be applied to content hashed chunks
+		filename: "[name].js",
+		{{- else}}
 		filename: "[name].[contenthash:8].js",
+		{{- end}}
 		libraryTarget: "umd",
 		library: "[name]",
-		clean: true,
+		clean: {{if .HMR}}false{{else}}true{{end}},
 	},
 	module: {
 		rules: [
@@ -142,13 +284,17 @@ module.exports = {
 				use: {
 					loader: "babel-loader",
 					options: {
-						plugins: ['@babel/plugin-transform-react-jsx']
+						plugins: ['@babel/plugin-transform-react-jsx'{{if .HMR}}, require.resolve('react-refresh/babel'){{end}}]
 					}
 				}
 			},
 		]
 	},
 	optimization: {
+		{{- if .HMR}}
+		// keep the last working bundles while the client has errors
+		emitOnErrors: false,
+		{{- end}}
 		runtimeChunk: 'single',
 		chunkIds: 'deterministic',
 		splitChunks: {
@@ -167,7 +313,13 @@ module.exports = {
 		},
 	},
 	plugins: [
-		{{.HtmlWebpackPlugins}}
+		new GreactManifestPlugin(),
+		{{- if .HMR}}
+		new webpack.HotModuleReplacementPlugin(),
+		new GreactStatsPlugin(),
+		// build errors are shown by the greact overlay, from the stats
+		new ReactRefreshWebpackPlugin({ overlay: false }),
+		{{- end}}
 	],
 };`
 
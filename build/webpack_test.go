@@ -67,11 +67,11 @@ func Test_getWebpackConfig(t *testing.T) {
 				},
 			},
 			want: WebpackConfig{
-				EntryPoints:        "index: path.join(__dirname, 'src', 'index.js'),\n\t\tabout: path.join(__dirname, 'src', 'about.js'),\n\t\t",
-				HtmlWebpackPlugins: "new HtmlWebpackPlugin({\n\t\t\ttemplate: path.join(__dirname, 'build', '.greact-template.html'),\n\t\t\tfilename: 'index.html',\n\t\t\tchunks: ['hydrate', 'index'],\n\t\t\tpublicPath: '/',\n\t\t}),\n\t\tnew HtmlWebpackPlugin({\n\t\t\ttemplate: path.join(__dirname, 'build', '.greact-template.html'),\n\t\t\tfilename: 'about.html',\n\t\t\tchunks: ['hydrate', 'about'],\n\t\t\tpublicPath: '/',\n\t\t}),\n\t\t",
-				BuildFolder:        "build",
-				StaticFolder:       "static",
-				PublicPath:         "/",
+				EntryPoints:  "index: path.join(__dirname, 'src', 'index.js'),\n\t\tabout: path.join(__dirname, 'src', 'about.js'),\n\t\t",
+				BuildFolder:  "build",
+				StaticFolder: "static",
+				PublicPath:   "/",
+				HMRStatsFile: hmrStatsFileName,
 			},
 		},
 	}
@@ -83,3 +83,42 @@ func Test_getWebpackConfig(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseWebpackStats(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats string
+		want  []Diagnostic
+	}{
+		{
+			name:  "Test no errors",
+			stats: `{"errors": []}`,
+			want:  nil,
+		},
+		{
+			name:  "Test module not found",
+			stats: `{"errors": [{"moduleName": "./pages/index.js", "loc": "3:0-30", "message": "Module not found: Error: Can't resolve './Button' in '/app/client/pages'"}]}`,
+			want: []Diagnostic{
+				{File: "client/pages/index.js", Line: 3, Column: 1, Message: "Module not found: Error: Can't resolve './Button' in '/app/client/pages'"},
+			},
+		},
+		{
+			name:  "Test babel syntax error",
+			stats: `{"errors": [{"moduleName": "./pages/about.js", "message": "Module build failed (from ./node_modules/babel-loader/lib/index.js):\nSyntaxError: /app/client/pages/about.js: Unexpected token (5:4)\n\n  3 | const About = () => {\n> 5 |     <div>\n"}]}`,
+			want: []Diagnostic{
+				{File: "client/pages/about.js", Line: 5, Column: 5, Message: "Unexpected token"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWebpackStats([]byte(tt.stats), "client")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWebpackStats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
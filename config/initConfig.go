@@ -1,7 +1,15 @@
 package config
 
 import (
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -10,79 +18,192 @@ const (
 	DefaultConfigFileName = "greact.env"
 )
 
-var (
-	configFileName string
-)
+// defaults are written to the config file for the keys not given on init
+var defaults = map[string]string{
+	"clientPath":   "./client",
+	"sourceFolder": "pages",
+	"buildFolder":  "build",
+	"staticFolder": "static",
+	"publicPath":   "/public/",
+	"bundler":      DefaultBundler,
+}
 
-func InitConfig() {
-	setConfigFileName()
-	setConfigDefaults()
-	getUserInput()
-	createConfigFile()
+// prompts are asked for on an interactive init, in order
+var prompts = []struct {
+	key      string
+	question string
+}{
+	{"clientPath", "the path to the client directory"},
+	{"sourceFolder", "the source folder name"},
+	{"buildFolder", "the build folder name"},
+	{"staticFolder", "the static files folder name"},
+	{"publicPath", "the public path"},
+	{"bundler", "the bundler, webpack or esbuild"},
 }
 
-func createConfigFile() {
-	err := viper.WriteConfig()
+// InitConfig is the main function of the init command. Every config key can
+// be given as a flag, e.g. -client-path; the others are prompted for, unless
+// -yes is given or stdin isn't a terminal, in which case the defaults are used.
+func InitConfig(args []string) error {
+	_, err := InitConfigFlags(flag.NewFlagSet("init", flag.ExitOnError), args)
+	return err
+}
 
-	if err != nil {
-		fmt.Println("Error creating config file: " + err.Error())
-		return
+// InitConfigFlags is InitConfig with a flag set holding the flags of the
+// caller, and returns the path of the created config file
+func InitConfigFlags(flagSet *flag.FlagSet, args []string) (string, error) {
+	var (
+		fileName string
+		yes      bool
+		force    bool
+	)
+	values := map[string]*string{}
+
+	flagSet.StringVar(&fileName, "file", DefaultConfigFileName, "name of the config file, its extension sets the format: .env, .yaml, .yml, .toml or .json")
+	flagSet.BoolVar(&yes, "yes", false, "accept the defaults instead of prompting")
+	flagSet.BoolVar(&yes, "y", false, "accept the defaults instead of prompting")
+	flagSet.BoolVar(&force, "force", false, "overwrite an existing config file")
+	for _, key := range Keys() {
+		values[key] = flagSet.String(FlagName(key), "", fmt.Sprintf("%s, written to the config file", key))
 	}
 
-	fmt.Println("Config file created: " + configFileName)
-}
+	flagSet.Usage = func() {
+		fmt.Println("usage: greact init [options]")
+		fmt.Println()
+		fmt.Println("options:")
+		flagSet.PrintDefaults()
+	}
+
+	flagSet.Parse(args)
+
+	given := map[string]bool{}
+	flagSet.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	interactive := !yes && isTerminal(os.Stdin)
+	reader := bufio.NewReader(os.Stdin)
 
-func getUserInput() {
-	var config = Config{}
+	if interactive && !given["file"] {
+		name, err := prompt(reader, "the name of the config file", fileName)
+		if err != nil {
+			return "", err
+		}
+		fileName = name
+	}
 
-	fmt.Print("Enter the path to the client directory [default: " + viper.GetString("clientPath") + "]: ")
-	fmt.Scanln(&config.ClientPath)
-	if config.ClientPath != "" {
-		viper.Set("clientPath", config.ClientPath)
+	if err := checkExistingConfig(fileName, force); err != nil {
+		return "", err
 	}
 
-	fmt.Print("Enter the source folder name [default: " + viper.GetString("sourceFolder") + "]: ")
-	fmt.Scanln(&config.SourceFolder)
-	if config.SourceFolder != "" {
-		viper.Set("sourceFolder", config.SourceFolder)
+	v := viper.New()
+	v.SetConfigFile(fileName)
+	for key, value := range defaults {
+		v.Set(key, value)
 	}
 
-	fmt.Print("Enter the build folder name [default: " + viper.GetString("buildFolder") + "]: ")
-	fmt.Scanln(&config.BuildFolder)
-	if config.BuildFolder != "" {
-		viper.Set("buildFolder", config.BuildFolder)
+	for key, value := range values {
+		if given[FlagName(key)] {
+			v.Set(key, *value)
+		}
 	}
 
-	fmt.Print("Enter the static files folder name [default: " + viper.GetString("staticFolder") + "]: ")
-	fmt.Scanln(&config.StaticFolder)
-	if config.StaticFolder != "" {
-		viper.Set("staticFolder", config.StaticFolder)
+	if interactive {
+		for _, p := range prompts {
+			if given[FlagName(p.key)] {
+				continue
+			}
+
+			value, err := prompt(reader, p.question, v.GetString(p.key))
+			if err != nil {
+				return "", err
+			}
+			v.Set(p.key, value)
+		}
 	}
 
-	fmt.Print("Enter the public path [default: " + viper.GetString("publicPath") + "]: ")
-	fmt.Scanln(&config.PublicPath)
-	if config.PublicPath != "" {
-		viper.Set("publicPath", config.PublicPath)
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		return "", err
+	}
+	// the client is created by the first build
+	if err := ValidateNewConfig(config); err != nil {
+		return "", fmt.Errorf("invalid config: %w", err)
 	}
 
+	// the flags and prompts are strings, the ports are written as numbers
+	typed := reflect.ValueOf(config)
+	for i, key := range Keys() {
+		if v.IsSet(key) && typed.Field(i).Kind() == reflect.Int {
+			v.Set(key, typed.Field(i).Interface())
+		}
+	}
+
+	if err := v.WriteConfig(); err != nil {
+		return "", fmt.Errorf("error creating config file: %w", err)
+	}
+
+	fmt.Println("Config file created: " + fileName)
+
+	return fileName, nil
 }
 
-func setConfigDefaults() {
-	viper.SetDefault("clientPath", "./client")
-	viper.SetDefault("sourceFolder", "pages")
-	viper.SetDefault("buildFolder", "build")
-	viper.SetDefault("staticFolder", "static")
-	viper.SetDefault("publicPath", "/public/")
+// checkExistingConfig refuses to overwrite a config file, or to create one
+// next to another that the commands would pick instead, unless forced
+func checkExistingConfig(fileName string, force bool) error {
+	if force {
+		return nil
+	}
+
+	if _, err := os.Stat(fileName); err == nil {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", fileName)
+	}
+
+	if existing, err := FindConfigFile(filepath.Dir(fileName)); err == nil {
+		return fmt.Errorf("%s already exists, use -force to create %s anyway", existing, fileName)
+	}
+
+	return nil
 }
 
-func setConfigFileName() {
-	fmt.Print("Enter the name of the config file [default: " + DefaultConfigFileName + "]: ")
-	fmt.Scanln(&configFileName)
+// prompt asks for a value, returning the default on an empty answer
+func prompt(reader *bufio.Reader, question string, defaultValue string) (string, error) {
+	fmt.Print("Enter " + question + " [default: " + defaultValue + "]: ")
+
+	answer, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return defaultValue, nil
+	}
+
+	return answer, nil
+}
+
+// isTerminal reports whether f is a terminal, as opposed to a pipe or a file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// flagNames are the command line flags not named after their key
+var flagNames = map[string]string{
+	"devPublicURL": "dev-url",
+	"devProxyPort": "proxy-port",
+}
 
-	if configFileName == "" {
-		configFileName = DefaultConfigFileName
+// FlagName returns the command line flag of key, e.g. client-path for
+// clientPath, the same for every command
+func FlagName(key string) string {
+	if name, ok := flagNames[key]; ok {
+		return name
 	}
 
-	viper.SetConfigFile(configFileName)
-	viper.SetConfigType("dotenv")
+	name := strings.TrimPrefix(EnvName(key), EnvPrefix)
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
 }
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFlagName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"clientPath", "client-path"},
+		{"devPort", "dev-port"},
+		{"devPublicURL", "dev-url"},
+		{"devProxyPort", "proxy-port"},
+		{"appURL", "app-url"},
+		{"goBuildFlags", "go-build-flags"},
+	}
+
+	for _, tt := range tests {
+		if got := FlagName(tt.key); got != tt.want {
+			t.Errorf("FlagName(%s) = %s, want %s", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestInitConfig(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "greact.yaml")
+
+	err := InitConfig([]string{"-yes", "-file", fileName, "-bundler", "esbuild", "-dev-port", "3001", "-proxy-port", "3002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the ports are numbers, not strings
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"devport: 3001\n", "devproxyport: 3002\n"} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("InitConfig() wrote\n%s\nwant the line %q", data, line)
+		}
+	}
+
+	if err := LoadConfig(fileName); err != nil {
+		t.Fatal(err)
+	}
+	got := GetConfig()
+	if got.ClientPath != filepath.Join(dir, "client") || got.Bundler != "esbuild" || got.DevPort != 3001 || got.DevProxyPort != 3002 {
+		t.Errorf("InitConfig() wrote %+v, want the defaults with the bundler and dev port of the flags", got)
+	}
+
+	// an existing config is only overwritten with -force
+	if err := InitConfig([]string{"-yes", "-file", fileName}); err == nil {
+		t.Error("InitConfig() on an existing config error = nil, want an error")
+	}
+	if err := InitConfig([]string{"-yes", "-file", filepath.Join(dir, "greact.json")}); err == nil {
+		t.Error("InitConfig() next to another config error = nil, want an error")
+	}
+	if err := InitConfig([]string{"-yes", "-force", "-file", fileName}); err != nil {
+		t.Errorf("InitConfig() with -force error = %v", err)
+	}
+
+	// an invalid config isn't written
+	invalid := filepath.Join(t.TempDir(), "greact.env")
+	if err := InitConfig([]string{"-yes", "-file", invalid, "-bundler", "rollup"}); err == nil {
+		t.Error("InitConfig() with an invalid bundler error = nil, want an error")
+	}
+	if _, err := os.Stat(invalid); !os.IsNotExist(err) {
+		t.Errorf("InitConfig() with an invalid bundler wrote %s", invalid)
+	}
+}
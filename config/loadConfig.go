@@ -1,52 +1,354 @@
 package config
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unicode"
+
 	"github.com/spf13/viper"
 )
 
+// The config is merged from, by increasing precedence:
+//   - the config file (greact.env, greact.yaml, greact.toml or greact.json)
+//   - the profile file, e.g. greact.production.env
+//   - the GREACT_* environment variables, e.g. GREACT_CLIENT_PATH
+//   - the command line flags
 type Config struct {
-	ClientPath   string `json:"clientPath"`
-	SourceFolder string `json:"sourceFolder"`
-	BuildFolder  string `json:"buildFolder"`
-	StaticFolder string `json:"staticFolder"`
-	PublicPath   string `json:"publicPath"`
+	ClientPath     string `json:"clientPath"`
+	SourceFolder   string `json:"sourceFolder"`
+	BuildFolder    string `json:"buildFolder"`
+	StaticFolder   string `json:"staticFolder"`
+	PublicPath     string `json:"publicPath"`
+	Bundler        string `json:"bundler"`
+	PackageManager string `json:"packageManager"`
+	DevHost        string `json:"devHost"`
+	DevPort        int    `json:"devPort"`
+	DevPublicURL   string `json:"devPublicURL"`
+	DevProxyPort   int    `json:"devProxyPort"`
+	DevHealthPath  string `json:"devHealthPath"`
+	AppURL         string `json:"appURL"`
+	// Template is the html/template document of the pages, relative to the client path
+	Template string `json:"template"`
+	// GoMain, GoBuildFlags, GoOutDir and GoDir configure how the Go app is built and run
+	GoMain       string `json:"goMain"`
+	GoBuildFlags string `json:"goBuildFlags"`
+	GoOutDir     string `json:"goOutDir"`
+	GoDir        string `json:"goDir"`
+	// WatchInclude and WatchIgnore are comma separated globs, relative to the client path
+	WatchInclude []string `json:"watchInclude"`
+	WatchIgnore  []string `json:"watchIgnore"`
+}
+
+const (
+	// EnvPrefix prefixes the environment variables overriding the config
+	EnvPrefix = "GREACT_"
+	// ProfileEnv selects the profile when Profile isn't set
+	ProfileEnv = "GREACT_ENV"
+)
+
+// The defaults of the keys the config leaves out
+const (
+	DefaultBundler       = "webpack"
+	DefaultDevPort       = 1501
+	DefaultDevProxyPort  = 3000
+	DefaultDevHealthPath = "/"
+	DefaultAppURL        = "http://localhost:8080"
+	DefaultGoMain        = "."
+)
+
+// builtinDefaults are the values of the keys set nowhere else, the paths
+// default to empty, e.g. an empty goDir is the directory of the config file
+var builtinDefaults = map[string]interface{}{
+	"bundler":       DefaultBundler,
+	"devPort":       DefaultDevPort,
+	"devProxyPort":  DefaultDevProxyPort,
+	"devHealthPath": DefaultDevHealthPath,
+	"appURL":        DefaultAppURL,
+	"goMain":        DefaultGoMain,
 }
 
+// ConfigFileNames are looked up in order when no config file is given
+var ConfigFileNames = []string{"greact.env", "greact.yaml", "greact.yml", "greact.toml", "greact.json"}
+
 var config = Config{}
 
+// The paths are absolute, relative ones in the config being anchored at Dir
 var (
 	BuildPath  string
 	SourcePath string
 	StaticPath string
 	IsLoaded   bool
+	// Dir is the directory of the config file
+	Dir string
+	// Profile is layered over the config file, e.g. production loads
+	// greact.production.env over greact.env
+	Profile string
+)
+
+var (
+	v = newViper()
+	// configFile is the path of the loaded config file
+	configFile string
+	// profileFile is the path of the loaded profile file, profileConfig its values
+	profileFile   string
+	profileConfig = viper.New()
+	// overrides maps the keys set on the command line to their flag
+	overrides = map[string]override{}
 )
 
+type override struct {
+	flag  string
+	value string
+}
+
+// FindConfigFile returns the first of ConfigFileNames existing in dir
+func FindConfigFile(dir string) (string, error) {
+	for _, name := range ConfigFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("no config file found, expected one of %s (run greact init to create one)", strings.Join(ConfigFileNames, ", "))
+}
+
+// LoadConfig loads the config file at path, the format following its
+// extension, or the one found in the current directory when path is empty
 func LoadConfig(path string) error {
+	if path == "" {
+		found, err := FindConfigFile(".")
+		if err != nil {
+			return err
+		}
+		path = found
+	}
+
 	// read the config file
-	viper.SetConfigFile(path)
-	err := viper.ReadInConfig()
+	loaded := newViper()
+	loaded.SetConfigFile(path)
+	err := loaded.ReadInConfig()
 
 	if err != nil {
 		return err
 	}
 
+	// the profile file is optional
+	profile, profilePath := ActiveProfile(), ""
+	profileValues := viper.New()
+	if profile != "" {
+		if _, err := os.Stat(ProfileFile(path, profile)); err == nil {
+			profilePath = ProfileFile(path, profile)
+			profileValues.SetConfigFile(profilePath)
+			if err := profileValues.ReadInConfig(); err != nil {
+				return err
+			}
+
+			if err := loaded.MergeConfigMap(profileValues.AllSettings()); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range Keys() {
+		loaded.BindEnv(key, EnvName(key))
+	}
+	for key, o := range overrides {
+		loaded.Set(key, o.value)
+	}
+
+	v, configFile = loaded, path
+	profileConfig, profileFile = profileValues, profilePath
+
 	// get the config values
-	err = viper.Unmarshal(&config)
+	err = unmarshal()
 
 	if err != nil {
 		return err
 	}
 
-	// set the build and source paths
-	BuildPath = config.ClientPath + "/" + config.BuildFolder
-	SourcePath = config.ClientPath + "/" + config.SourceFolder
-	StaticPath = config.ClientPath + "/" + config.StaticFolder
-
 	IsLoaded = true
 
 	return nil
 }
 
+// newViper returns a viper instance holding the builtin defaults
+func newViper() *viper.Viper {
+	loaded := viper.New()
+	for key, value := range builtinDefaults {
+		loaded.SetDefault(key, value)
+	}
+
+	return loaded
+}
+
+// ActiveProfile returns Profile, or the one of the GREACT_ENV variable
+func ActiveProfile() string {
+	if Profile != "" {
+		return Profile
+	}
+
+	return os.Getenv(ProfileEnv)
+}
+
+// ProfileFile returns the file of profile layered over the config file at
+// path, e.g. greact.production.yaml for greact.yaml
+func ProfileFile(path string, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+// Override sets a config key from the command line flag, over the config
+// file and the environment
+func Override(key string, flag string, value string) error {
+	if !isKey(key) {
+		return fmt.Errorf("unknown config key %s", key)
+	}
+
+	overrides[key] = override{flag: flag, value: value}
+	v.Set(key, value)
+
+	return unmarshal()
+}
+
+// ClearOverrides forgets the keys set on the command line. Only the next
+// LoadConfig takes it into account.
+func ClearOverrides() {
+	overrides = map[string]override{}
+}
+
+func unmarshal() error {
+	var loaded Config
+	if err := v.Unmarshal(&loaded); err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(configFile))
+	if err != nil {
+		return err
+	}
+	Dir = dir
+
+	// the paths don't depend on the working directory of the process
+	loaded.ClientPath = resolvePath(loaded.ClientPath)
+	loaded.GoDir = resolvePath(loaded.GoDir)
+	loaded.GoOutDir = resolvePath(loaded.GoOutDir)
+	config = loaded
+
+	// set the build and source paths
+	BuildPath = filepath.Join(config.ClientPath, config.BuildFolder)
+	SourcePath = filepath.Join(config.ClientPath, config.SourceFolder)
+	StaticPath = filepath.Join(config.ClientPath, config.StaticFolder)
+
+	return nil
+}
+
+// resolvePath anchors a relative path at Dir, an empty path stays empty
+func resolvePath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(Dir, path)
+}
+
 func GetConfig() Config {
 	return config
 }
+
+// Snapshot returns a function restoring the loaded config as it is now, e.g.
+// for a test loading its own config
+func Snapshot() func() {
+	saved, savedV, savedFile := config, v, configFile
+	buildPath, sourcePath, staticPath := BuildPath, SourcePath, StaticPath
+	isLoaded, dir, profile := IsLoaded, Dir, Profile
+	savedProfileFile, savedProfileConfig := profileFile, profileConfig
+	savedOverrides := map[string]override{}
+	for key, o := range overrides {
+		savedOverrides[key] = o
+	}
+
+	return func() {
+		config, v, configFile = saved, savedV, savedFile
+		BuildPath, SourcePath, StaticPath = buildPath, sourcePath, staticPath
+		IsLoaded, Dir, Profile = isLoaded, dir, profile
+		profileFile, profileConfig = savedProfileFile, savedProfileConfig
+		overrides = savedOverrides
+	}
+}
+
+// Field is a config value and where it comes from
+type Field struct {
+	Key    string
+	Value  interface{}
+	Source string
+}
+
+// Fields returns the effective config, in the order of the Config struct,
+// with the source of every value
+func Fields() []Field {
+	values := reflect.ValueOf(config)
+
+	var fields []Field
+	for i, key := range Keys() {
+		source := "default"
+		if o, ok := overrides[key]; ok {
+			source = "flag " + o.flag
+		} else if _, ok := os.LookupEnv(EnvName(key)); ok {
+			source = "env " + EnvName(key)
+		} else if profileConfig.InConfig(strings.ToLower(key)) {
+			source = "file " + profileFile
+		} else if v.InConfig(strings.ToLower(key)) {
+			source = "file " + configFile
+		}
+
+		fields = append(fields, Field{Key: key, Value: values.Field(i).Interface(), Source: source})
+	}
+
+	return fields
+}
+
+// Keys returns the config keys, in the order of the Config struct
+func Keys() []string {
+	t := reflect.TypeOf(Config{})
+
+	keys := make([]string, t.NumField())
+	for i := range keys {
+		keys[i] = t.Field(i).Tag.Get("json")
+	}
+
+	return keys
+}
+
+func isKey(key string) bool {
+	for _, k := range Keys() {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EnvName returns the environment variable overriding key, e.g.
+// GREACT_DEV_PUBLIC_URL for devPublicURL
+func EnvName(key string) string {
+	runes := []rune(key)
+
+	var name strings.Builder
+	name.WriteString(EnvPrefix)
+	for i, r := range runes {
+		// a new word starts at an upper case letter after a lower case one,
+		// or at the last letter of an acronym followed by a lower case one
+		if i > 0 && unicode.IsUpper(r) &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			name.WriteRune('_')
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+
+	return name.String()
+}
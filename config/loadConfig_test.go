@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"clientPath":   "GREACT_CLIENT_PATH",
+		"devPublicURL": "GREACT_DEV_PUBLIC_URL",
+		"appURL":       "GREACT_APP_URL",
+		"goOutDir":     "GREACT_GO_OUT_DIR",
+		"bundler":      "GREACT_BUNDLER",
+	}
+
+	for key, want := range tests {
+		if got := EnvName(key); got != want {
+			t.Errorf("EnvName(%s) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	files := map[string]string{
+		"greact.env":  "CLIENTPATH=./client\nBUNDLER=webpack\nDEVPORT=2000\n",
+		"greact.yaml": "clientPath: ./client\nbundler: webpack\ndevPort: 2000\n",
+		"greact.toml": "clientPath = \"./client\"\nbundler = \"webpack\"\ndevPort = 2000\n",
+		"greact.json": `{"clientPath": "./client", "bundler": "webpack", "devPort": 2000}`,
+	}
+
+	for name, contents := range files {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			path, err := FindConfigFile(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Setenv("GREACT_BUNDLER", "esbuild")
+			t.Setenv("GREACT_DEV_PORT", "3000")
+			if err := Override("devPort", "-dev-port", "4000"); err != nil {
+				t.Fatal(err)
+			}
+			defer ClearOverrides()
+
+			if err := LoadConfig(path); err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string]Field{}
+			for _, field := range Fields() {
+				got[field.Key] = field
+			}
+
+			// flags > environment > file, the paths anchored at the config file
+			want := map[string]Field{
+				"clientPath": {Key: "clientPath", Value: filepath.Join(dir, "client"), Source: "file " + path},
+				"bundler":    {Key: "bundler", Value: "esbuild", Source: "env GREACT_BUNDLER"},
+				"devPort":    {Key: "devPort", Value: 4000, Source: "flag -dev-port"},
+				// the keys set nowhere have their effective default
+				"devProxyPort": {Key: "devProxyPort", Value: DefaultDevProxyPort, Source: "default"},
+				"appURL":       {Key: "appURL", Value: DefaultAppURL, Source: "default"},
+				"goMain":       {Key: "goMain", Value: DefaultGoMain, Source: "default"},
+			}
+			for key, field := range want {
+				if !reflect.DeepEqual(got[key], field) {
+					t.Errorf("Fields()[%s] = %v, want %v", key, got[key], field)
+				}
+			}
+		})
+	}
+}
+
+func TestFindConfigFile(t *testing.T) {
+	if _, err := FindConfigFile(t.TempDir()); err == nil {
+		t.Error("FindConfigFile() error = nil, want an error without a config file")
+	}
+}
+
+func TestLoadConfig_profile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "greact.yaml")
+	os.WriteFile(path, []byte("clientPath: ./client\npublicPath: /public/\nbundler: webpack\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "greact.production.yaml"), []byte("publicPath: /static/\n"), 0644)
+
+	tests := []struct {
+		name           string
+		profile        string
+		env            string
+		wantPublicPath string
+		wantSource     string
+	}{
+		{"no profile", "", "", "/public/", "file " + path},
+		{"profile", "production", "", "/static/", "file " + filepath.Join(dir, "greact.production.yaml")},
+		{"GREACT_ENV", "", "production", "/static/", "file " + filepath.Join(dir, "greact.production.yaml")},
+		{"profile over GREACT_ENV", "development", "production", "/public/", "file " + path},
+		{"missing profile file", "test", "", "/public/", "file " + path},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Profile = tt.profile
+			defer func() { Profile = "" }()
+			t.Setenv(ProfileEnv, tt.env)
+
+			if err := LoadConfig(path); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := GetConfig().PublicPath; got != tt.wantPublicPath {
+				t.Errorf("publicPath = %v, want %v", got, tt.wantPublicPath)
+			}
+			for _, field := range Fields() {
+				if field.Key == "publicPath" && field.Source != tt.wantSource {
+					t.Errorf("publicPath source = %v, want %v", field.Source, tt.wantSource)
+				}
+			}
+			// the keys missing from the profile come from the config file
+			if got := GetConfig().Bundler; got != "webpack" {
+				t.Errorf("bundler = %v, want webpack", got)
+			}
+		})
+	}
+}
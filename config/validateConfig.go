@@ -2,28 +2,152 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
+// ValidationError is an invalid config value
+type ValidationError struct {
+	Field  string
+	Value  interface{}
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	value := fmt.Sprint(e.Value)
+	if value == "" {
+		return e.Field + " " + e.Reason
+	}
+
+	return fmt.Sprintf("%s %s, got %q", e.Field, e.Reason, value)
+}
+
+// ValidationErrors are all the invalid values of a config
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = "\n  - " + err.Error()
+	}
+
+	return fmt.Sprintf("%d errors:%s", len(e), strings.Join(lines, ""))
+}
+
+// ValidateConfig returns the ValidationErrors of config, nil if it is valid
 func ValidateConfig(config Config) error {
-	if config.ClientPath == "" {
-		return fmt.Errorf("clientPath is empty")
+	errs := validate(config)
+
+	if config.ClientPath != "" {
+		if info, err := os.Stat(config.ClientPath); err != nil {
+			errs = append(errs, ValidationError{"clientPath", config.ClientPath, "doesn't exist"})
+		} else if !info.IsDir() {
+			errs = append(errs, ValidationError{"clientPath", config.ClientPath, "isn't a directory"})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// ValidateNewConfig is ValidateConfig for a client that doesn't exist yet,
+// e.g. on init
+func ValidateNewConfig(config Config) error {
+	if errs := validate(config); len(errs) > 0 {
+		return errs
 	}
 
-	if config.SourceFolder == "" {
-		return fmt.Errorf("sourceFolder is empty")
+	return nil
+}
+
+func validate(config Config) ValidationErrors {
+	var errs ValidationErrors
+	add := func(field string, value interface{}, reason string) {
+		errs = append(errs, ValidationError{Field: field, Value: value, Reason: reason})
 	}
 
-	if config.BuildFolder == "" {
-		return fmt.Errorf("buildFolder is empty")
+	if config.ClientPath == "" {
+		add("clientPath", config.ClientPath, "is required")
 	}
 
-	if config.StaticFolder == "" {
-		return fmt.Errorf("staticFolder is empty")
+	folders := []struct {
+		field string
+		value string
+	}{
+		{"sourceFolder", config.SourceFolder},
+		{"buildFolder", config.BuildFolder},
+		{"staticFolder", config.StaticFolder},
+	}
+	seen := map[string]string{}
+	for _, folder := range folders {
+		if folder.value == "" {
+			add(folder.field, folder.value, "is required")
+			continue
+		}
+
+		// the folders live inside the client, the build one gets cleaned
+		clean := filepath.Clean(folder.value)
+		if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			add(folder.field, folder.value, "must be a folder inside clientPath")
+			continue
+		}
+
+		if other, ok := seen[clean]; ok {
+			add(folder.field, folder.value, "must be distinct from "+other)
+			continue
+		}
+		seen[clean] = folder.field
 	}
 
 	if config.PublicPath == "" {
-		return fmt.Errorf("publicPath is empty")
+		add("publicPath", config.PublicPath, "is required")
+	} else if !strings.HasPrefix(config.PublicPath, "/") || !strings.HasSuffix(config.PublicPath, "/") {
+		add("publicPath", config.PublicPath, "must start and end with a slash")
 	}
 
-	return nil
+	// an empty bundler falls back to webpack
+	if config.Bundler != "" && config.Bundler != "webpack" && config.Bundler != "esbuild" {
+		add("bundler", config.Bundler, "must be webpack or esbuild")
+	}
+
+	// an empty package manager is detected from the lockfiles
+	switch config.PackageManager {
+	case "", "npm", "yarn", "pnpm", "bun":
+	default:
+		add("packageManager", config.PackageManager, "must be npm, yarn, pnpm or bun")
+	}
+
+	if config.DevPort < 0 || config.DevPort > 65535 {
+		add("devPort", config.DevPort, "must be between 0 and 65535")
+	}
+
+	if config.DevPublicURL != "" && !strings.HasPrefix(config.DevPublicURL, "ws://") && !strings.HasPrefix(config.DevPublicURL, "wss://") {
+		add("devPublicURL", config.DevPublicURL, "must start with ws:// or wss://")
+	}
+
+	if config.DevProxyPort < 0 || config.DevProxyPort > 65535 {
+		add("devProxyPort", config.DevProxyPort, "must be between 0 and 65535")
+	}
+
+	if config.DevHealthPath != "" && !strings.HasPrefix(config.DevHealthPath, "/") {
+		add("devHealthPath", config.DevHealthPath, "must start with a slash")
+	}
+
+	if config.AppURL != "" {
+		appURL, err := url.Parse(config.AppURL)
+		if err != nil || (appURL.Scheme != "http" && appURL.Scheme != "https") || appURL.Host == "" {
+			add("appURL", config.AppURL, "must be an http(s) URL")
+		}
+	}
+
+	return errs
 }
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	valid := Config{
+		ClientPath:   t.TempDir(),
+		SourceFolder: "pages",
+		BuildFolder:  "build",
+		StaticFolder: "static",
+		PublicPath:   "/public/",
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string
+	}{
+		{"valid", func(c *Config) {}, nil},
+		{"missing client", func(c *Config) { c.ClientPath += "/missing" }, []string{"clientPath"}},
+		{"public path without slashes", func(c *Config) { c.PublicPath = "public" }, []string{"publicPath"}},
+		{"escaping folder", func(c *Config) { c.BuildFolder = "../build" }, []string{"buildFolder"}},
+		{"absolute folder", func(c *Config) { c.StaticFolder = "/static" }, []string{"staticFolder"}},
+		{"client folder", func(c *Config) { c.StaticFolder = "." }, []string{"staticFolder"}},
+		{"same folders", func(c *Config) { c.StaticFolder = "./build/" }, []string{"staticFolder"}},
+		{
+			"every issue at once",
+			func(c *Config) { c.SourceFolder, c.Bundler, c.DevPort = "", "rollup", -1 },
+			[]string{"sourceFolder", "bundler", "devPort"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.modify(&c)
+
+			err := ValidateConfig(c)
+
+			var got []string
+			var errs ValidationErrors
+			if errors.As(err, &errs) {
+				for _, e := range errs {
+					got = append(got, e.Field)
+				}
+			} else if err != nil {
+				t.Fatalf("ValidateConfig() error = %v, want ValidationErrors", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateConfig() fields = %v, want %v (%v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidateNewConfig(t *testing.T) {
+	err := ValidateNewConfig(Config{
+		ClientPath:   t.TempDir() + "/missing",
+		SourceFolder: "pages",
+		BuildFolder:  "build",
+		StaticFolder: "static",
+		PublicPath:   "/public/",
+	})
+	if err != nil {
+		t.Errorf("ValidateNewConfig() error = %v, want nil for a missing client", err)
+	}
+}
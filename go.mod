@@ -2,7 +2,10 @@ module github.com/shynxe/greact
 
 go 1.19
 
-require github.com/spf13/viper v1.14.0
+require (
+	github.com/evanw/esbuild v0.28.2
+	github.com/spf13/viper v1.14.0
+)
 
 require (
 	github.com/rogpeppe/go-internal v1.8.0 // indirect
@@ -21,7 +24,7 @@ require (
 	github.com/spf13/cast v1.5.0 // indirect
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	github.com/subosito/gotenv v1.4.1 // indirect
+	github.com/subosito/gotenv v1.4.1
 	golang.org/x/sys v0.3.0 // indirect
 	golang.org/x/text v0.5.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
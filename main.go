@@ -6,7 +6,6 @@ import (
 	"os"
 
 	"github.com/shynxe/greact/build"
-	"github.com/shynxe/greact/config"
 )
 
 const (
@@ -35,13 +34,25 @@ func main() {
 	if command != "" {
 		switch command {
 		case "build":
-			build.Build(os.Args[2:])
+			// the error is already printed with its diagnostics
+			if err := build.Build(os.Args[2:]); err != nil {
+				os.Exit(1)
+			}
 		case "run":
 			build.Run(os.Args[2:])
 		case "dev":
 			build.Dev(os.Args[2:])
+		case "new":
+			build.New(os.Args[2:])
+		case "doctor":
+			build.Doctor(os.Args[2:])
+		case "config":
+			build.Config(os.Args[2:])
 		case "init":
-			config.InitConfig()
+			if err := build.Init(os.Args[2:]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
 		case "help":
 			flag.Usage()
 		default:
@@ -67,7 +78,10 @@ func parseArgs() {
 		fmt.Println("  build\t\tbuild the react pages")
 		fmt.Println("  run\t\tstart the server")
 		fmt.Println("  dev\t\trun dev mode")
-		fmt.Println("  init\t\tinitialize the config file")
+		fmt.Println("  new\t\tcreate a page or a component: new page <name>, new component <Name>")
+		fmt.Println("  doctor\t\tcheck the environment and the project")
+		fmt.Println("  config show\tshow the effective config and where each value comes from")
+		fmt.Println("  init\t\tinitialize the config file, -scaffold also creates a runnable project")
 		fmt.Println("  help\t\tshow this help")
 	}
 
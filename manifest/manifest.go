@@ -0,0 +1,56 @@
+package manifest
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// FileName is the name of the manifest file written to the static folder
+const FileName = "manifest.json"
+
+// Manifest maps every page to the chunks it needs, in load order
+type Manifest struct {
+	PublicPath string          `json:"publicPath"`
+	Pages      map[string]Page `json:"pages"`
+}
+
+// Page holds the chunks of a single page
+type Page struct {
+	JS  []Asset `json:"js"`
+	CSS []Asset `json:"css"`
+	// Module is set when the JS chunks are ES modules
+	Module bool `json:"module,omitempty"`
+}
+
+// Asset is a single emitted file, relative to the public path
+type Asset struct {
+	File      string `json:"file"`
+	Integrity string `json:"integrity"`
+}
+
+// Load reads the manifest at path
+func Load(path string) (Manifest, error) {
+	var m Manifest
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return m, err
+	}
+
+	return m, nil
+}
+
+// Write writes the manifest to path
+func Write(path string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,84 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteLoad(t *testing.T) {
+	m := Manifest{
+		PublicPath: "/public/",
+		Pages: map[string]Page{
+			// the shared chunks come first, in load order
+			"index": {
+				JS: []Asset{
+					{File: "runtime.1a2b3c4d.js", Integrity: "sha384-runtime"},
+					{File: "npm.react.5e6f7a8b.js", Integrity: "sha384-react"},
+					{File: "index.9c0d1e2f.js", Integrity: "sha384-index"},
+				},
+				CSS: []Asset{{File: "index.3a4b5c6d.css", Integrity: "sha384-css"}},
+			},
+			"about": {
+				JS: []Asset{{File: "about.7a8b9c0d.js", Integrity: "sha384-about"}},
+			},
+			// esbuild emits ES modules
+			"contact": {
+				JS:     []Asset{{File: "contact-X7Y8Z9.js", Integrity: "sha384-contact"}},
+				Module: true,
+			},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := Write(path, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("Load() = %+v, want %+v", got, m)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		data    string
+		want    Manifest
+		wantErr bool
+	}{
+		{
+			// the shared chunks come first, in load order
+			name: "webpack manifest",
+			data: `{"publicPath":"/","pages":{"index":{"js":[{"file":"runtime.js","integrity":"sha384-a"},{"file":"index.js","integrity":"sha384-b"}],"css":[]}}}`,
+			want: Manifest{PublicPath: "/", Pages: map[string]Page{
+				"index": {JS: []Asset{{File: "runtime.js", Integrity: "sha384-a"}, {File: "index.js", Integrity: "sha384-b"}}, CSS: []Asset{}},
+			}},
+		},
+		{name: "invalid manifest", data: `{"pages":`, wantErr: true},
+		{name: "missing manifest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".json")
+			if tt.data != "" {
+				os.WriteFile(path, []byte(tt.data), 0644)
+			}
+
+			got, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
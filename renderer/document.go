@@ -0,0 +1,143 @@
+package renderer
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"text/template/parse"
+)
+
+// TemplateFileName is the document template written by the build, next to
+// the renderer
+const TemplateFileName = ".greact-template.html"
+
+// DevClientMarker is rendered in the DevClient slot in dev mode, and replaced
+// by the dev proxy with the refresh script
+const DevClientMarker = "<!-- greact:dev-client -->"
+
+// DevEnv is set in the environment of the app in dev mode
+const DevEnv = "GREACT_DEV"
+
+// DefaultTemplate is the document of the pages when the config has no template
+const DefaultTemplate = `<html>
+
+<head>
+  <title>SSR Demo</title>
+  <meta charset="utf-8" />
+  {{.Head}}
+  {{.DevClient}}
+</head>
+
+<body>
+  <div id="root" data-greact-root>{{.Body}}</div>
+  {{.Scripts}}
+</body>
+
+</html>`
+
+// Document holds the slots of the document template
+type Document struct {
+	// Page is the name of the rendered page
+	Page string
+	// Head loads the styles and scripts of the page
+	Head template.HTML
+	// Body is the server-side rendered page, to put in the element marked with
+	// data-greact-root (or with the root id)
+	Body template.HTML
+	// Scripts hydrates the page
+	Scripts template.HTML
+	// Props are the props of the page, as JSON
+	Props template.JS
+	// DevClient loads the dev client in dev mode, it is injected at the end of
+	// the head when the template leaves it out
+	DevClient template.HTML
+}
+
+// requiredSlots must appear in every document template
+var requiredSlots = []string{"Head", "Body", "Scripts"}
+
+// ParseTemplate parses a document template, and checks it uses the required
+// slots and only known ones
+func ParseTemplate(name string, text string) (*template.Template, error) {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{}
+	for _, tree := range t.Templates() {
+		if tree.Tree != nil {
+			collectFields(tree.Tree.Root, used)
+		}
+	}
+
+	var missing []string
+	for _, slot := range requiredSlots {
+		if !used[slot] {
+			missing = append(missing, "{{."+slot+"}}")
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("template %s is missing the %s slots", name, strings.Join(missing, ", "))
+	}
+
+	// unknown slots only fail on execution
+	if err := t.Execute(io.Discard, Document{}); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// collectFields adds the fields of the data used by the nodes to used
+func collectFields(node parse.Node, used map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectFields(child, used)
+		}
+	case *parse.ActionNode:
+		collectFields(n.Pipe, used)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectFields(cmd, used)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectFields(arg, used)
+		}
+	case *parse.FieldNode:
+		used[n.Ident[0]] = true
+	case *parse.IfNode:
+		collectBranch(&n.BranchNode, used)
+	case *parse.RangeNode:
+		collectBranch(&n.BranchNode, used)
+	case *parse.WithNode:
+		collectBranch(&n.BranchNode, used)
+	case *parse.TemplateNode:
+		collectFields(n.Pipe, used)
+	}
+}
+
+func collectBranch(n *parse.BranchNode, used map[string]bool) {
+	collectFields(n.Pipe, used)
+	collectFields(n.List, used)
+	collectFields(n.ElseList, used)
+}
+
+// devClient returns the dev client slot, empty outside of dev mode
+func devClient() template.HTML {
+	if os.Getenv(DevEnv) == "" {
+		return ""
+	}
+
+	return DevClientMarker
+}
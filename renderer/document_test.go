@@ -0,0 +1,41 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{"default", DefaultTemplate, ""},
+		{
+			"slots in branches",
+			`<html lang="en"><head>{{if .Head}}{{.Head}}{{end}}</head><body><main data-greact-root>{{with .Body}}{{.}}{{end}}</main>{{.Scripts}}</body></html>`,
+			"",
+		},
+		{"missing slot", `<html><head>{{.Head}}</head><body>{{.Body}}</body></html>`, "{{.Scripts}}"},
+		{"unknown slot", `{{.Head}}{{.Body}}{{.Scripts}}{{.Footer}}`, "Footer"},
+		{"syntax error", `{{.Head}`, "document.html:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTemplate("document.html", tt.text)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ParseTemplate() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseTemplate() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
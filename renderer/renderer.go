@@ -2,51 +2,130 @@ package renderer
 
 import (
 	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
 	"io/ioutil"
 	"log"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"github.com/shynxe/greact/config"
+	"github.com/shynxe/greact/manifest"
 )
 
+// AssetURL returns the URL an asset is served from. Replace it to rewrite the
+// asset URLs, e.g. to serve the chunks from a CDN.
+var AssetURL = func(publicPath string, file string) string {
+	return publicPath + file
+}
+
+// Preload adds a <link rel="preload"> hint for every chunk of the page
+var Preload = false
+
 func RenderPage(page string, props interface{}) string {
-	file, err := ioutil.ReadFile(config.StaticPath + "/" + page + ".html")
+	file, err := ioutil.ReadFile(filepath.Join(config.BuildPath, TemplateFileName))
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	document, err := ParseTemplate(TemplateFileName, string(file))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	m, err := manifest.Load(filepath.Join(config.StaticPath, manifest.FileName))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	assets, ok := m.Pages[page]
+	if !ok {
+		log.Fatal(fmt.Errorf("page %s not found in %s", page, manifest.FileName))
+	}
+
+	// json escapes <, > and &, so the props are safe in a script
 	jsonData, err := json.Marshal(props)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	html := string(file)
+	// the absolute path is quoted as a JS string, it may hold backslashes on windows
+	renderPath, err := json.Marshal(filepath.Join(config.BuildPath, "render.js"))
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// get the rendered html from the page component
-	cmd := exec.Command("node", "-e", "const page=require('"+config.BuildPath+"/render.js');console.log(page.default('"+page+"', "+string(jsonData)+"));")
+	cmd := exec.Command("node", "-e", "const page=require("+string(renderPath)+");console.log(page.default('"+page+"', "+string(jsonData)+"));")
 	stdout, err := cmd.Output()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// replace the script tag
-	html = strings.Replace(
-		html,
-		"// {{__HYDRATION__}}",
-		`
-		hydrate.default(`+page+`.default, `+string(jsonData)+`);
-		`,
-		1,
-	)
-
-	// replace the {{SSR}} tag with pre-rendered html
-	html = strings.Replace(
-		html,
-		"{{SSR}}",
-		string(stdout),
-		1,
-	)
-
-	return html
+	var html strings.Builder
+	err = document.Execute(&html, Document{
+		Page:      page,
+		Head:      template.HTML(assetTags(m.PublicPath, assets)),
+		Body:      template.HTML(stdout),
+		Scripts:   template.HTML(fmt.Sprintf(hydrationScript, page, jsonData)),
+		Props:     template.JS(jsonData),
+		DevClient: devClient(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return html.String()
+}
+
+// hydrationScript is formatted with the page name and its props
+const hydrationScript = `<script>
+  (function (fn) {
+    if (document.readyState != 'loading') {
+      fn();
+    } else {
+      document.addEventListener('DOMContentLoaded', fn);
+    }
+  })(function () {
+    hydrate.default(%s.default, %s, document.querySelector('[data-greact-root]') || undefined);
+  });
+</script>`
+
+// assetTags returns the link and script tags loading the page chunks, in load order
+func assetTags(publicPath string, page manifest.Page) string {
+	var tags []string
+
+	if Preload {
+		for _, asset := range page.CSS {
+			tags = append(tags, fmt.Sprintf(`<link rel="preload" as="style" href="%s" integrity="%s" crossorigin="anonymous">`, attr(AssetURL(publicPath, asset.File)), attr(asset.Integrity)))
+		}
+		rel := `rel="preload" as="script"`
+		if page.Module {
+			rel = `rel="modulepreload"`
+		}
+		for _, asset := range page.JS {
+			tags = append(tags, fmt.Sprintf(`<link %s href="%s" integrity="%s" crossorigin="anonymous">`, rel, attr(AssetURL(publicPath, asset.File)), attr(asset.Integrity)))
+		}
+	}
+
+	for _, asset := range page.CSS {
+		tags = append(tags, fmt.Sprintf(`<link rel="stylesheet" href="%s" integrity="%s" crossorigin="anonymous">`, attr(AssetURL(publicPath, asset.File)), attr(asset.Integrity)))
+	}
+
+	// module scripts are deferred by default
+	script := "defer"
+	if page.Module {
+		script = `type="module"`
+	}
+	for _, asset := range page.JS {
+		tags = append(tags, fmt.Sprintf(`<script %s src="%s" integrity="%s" crossorigin="anonymous"></script>`, script, attr(AssetURL(publicPath, asset.File)), attr(asset.Integrity)))
+	}
+
+	return strings.Join(tags, "\n  ")
+}
+
+func attr(s string) string {
+	return html.EscapeString(s)
 }